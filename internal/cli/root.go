@@ -149,8 +149,11 @@ func NewRootCmd() *cobra.Command {
 	cmd.Flags().IntVar(&opts.CoverageTestSize, "coverage-test-size", opts.CoverageTestSize, "coverage test size")
 	cmd.Flags().StringVar(&opts.SplitFilesDir, "split-files-dir", opts.SplitFilesDir, "directory for split files output")
 	cmd.Flags().StringVar(&opts.StructOutput, "struct-output", opts.StructOutput, "write generated struct declarations to file")
+	cmd.Flags().StringVar(&opts.UBManifest, "ub-manifest", opts.UBManifest, "write injected undefined-behaviour sites to file (JSON); defaults to <output>.ub.json when -o is given")
+	cmd.Flags().StringVar(&opts.WrapperSites, "wrapper-sites", opts.WrapperSites, "write the safe math wrapper sites numbered by --identify-wrappers to file (JSON); defaults to <output>.wrappers.json when -o is given")
+	cmd.Flags().StringVar(&opts.Runtime, "runtime", opts.Runtime, "how programs get the csmith runtime: include (from the include path), inline (copied into the program) or write (headers written next to the output)")
 	cmd.Flags().StringVar(&opts.DFSDebugSequence, "dfs-debug-sequence", opts.DFSDebugSequence, "debug sequence for dfs-exhaustive mode")
-	cmd.Flags().StringVar(&opts.PartialExpand, "partial-expand", opts.PartialExpand, "partial expansion strategy")
+	cmd.Flags().StringVar(&opts.PartialExpand, "partial-expand", opts.PartialExpand, "comma-separated statement kinds expanded exhaustively in dfs-exhaustive mode")
 	cmd.Flags().StringVar(&opts.DeltaMonitor, "delta-monitor", opts.DeltaMonitor, "delta monitor executable")
 	cmd.Flags().StringVar(&opts.DeltaOutput, "delta-output", opts.DeltaOutput, "delta output file")
 	cmd.Flags().StringVar(&opts.GoDelta, "go-delta", opts.GoDelta, "run selected delta reduction monitor")
@@ -158,7 +161,7 @@ func NewRootCmd() *cobra.Command {
 	cmd.Flags().StringVar(&opts.ProbabilityConfiguration, "probability-configuration", opts.ProbabilityConfiguration, "probability configuration file")
 	cmd.Flags().StringVar(&opts.DumpDefaultProbabilities, "dump-default-probabilities", opts.DumpDefaultProbabilities, "dump default probabilities to file")
 	cmd.Flags().StringVar(&opts.DumpRandomProbabilities, "dump-random-probabilities", opts.DumpRandomProbabilities, "dump randomized probabilities to file")
-	cmd.Flags().StringVar(&opts.SafeMathWrappers, "safe-math-wrappers", opts.SafeMathWrappers, "comma-separated safe math wrapper IDs")
+	cmd.Flags().StringVar(&opts.SafeMathWrappers, "safe-math-wrappers", opts.SafeMathWrappers, "comma-separated safe math wrapper IDs to keep (0 keeps none); the operations of the other sites are evaluated unguarded, so UBSan reports their undefined behaviour")
 	cmd.Flags().StringVar(&opts.MonitorFuncs, "monitor-funcs", opts.MonitorFuncs, "comma-separated list of functions to monitor")
 	cmd.Flags().StringVar(&opts.EnableBuiltinKinds, "enable-builtin-kinds", opts.EnableBuiltinKinds, "enable builtin kinds list")
 	cmd.Flags().StringVar(&opts.DisableBuiltinKinds, "disable-builtin-kinds", opts.DisableBuiltinKinds, "disable builtin kinds list")
@@ -184,7 +187,7 @@ func NewRootCmd() *cobra.Command {
 	addBoolPair(cmd, &negBindings, &opts.LongLong, "longlong", "enable long long")
 	addBoolPair(cmd, &negBindings, &opts.Int8, "int8", "enable int8_t")
 	addBoolPair(cmd, &negBindings, &opts.UInt8, "uint8", "enable uint8_t")
-	addBoolPair(cmd, &negBindings, &opts.EnableFloat, "float", "enable float")
+	addBoolPair(cmd, &negBindings, &opts.EnableFloat, "float", "enable float (with safe math, needs --runtime inline or write)")
 	addBoolPair(cmd, &negBindings, &opts.Math64, "math64", "enable 64-bit math")
 	addBoolPair(cmd, &negBindings, &opts.InlineFunction, "inline-function", "enable inline function attribute")
 	addBoolPair(cmd, &negBindings, &opts.Pointers, "pointers", "enable pointers")
@@ -214,7 +217,7 @@ func NewRootCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&opts.ConstAsCondition, "const-as-condition", opts.ConstAsCondition, "allow constants in conditions")
 	cmd.Flags().BoolVar(&opts.MatchExactQualifiers, "match-exact-qualifiers", opts.MatchExactQualifiers, "match exact qualifiers during selection")
 	cmd.Flags().BoolVar(&opts.FreshArrayCtrlVarNames, "fresh-array-ctrl-var-names", opts.FreshArrayCtrlVarNames, "use fresh array control variable names")
-	cmd.Flags().BoolVar(&opts.IdentifyWrappers, "identify-wrappers", opts.IdentifyWrappers, "annotate safe math wrappers")
+	cmd.Flags().BoolVar(&opts.IdentifyWrappers, "identify-wrappers", opts.IdentifyWrappers, "give every safe math wrapper call its own ID and count the operations each guards (needs --runtime inline or write)")
 	cmd.Flags().BoolVar(&opts.MarkMutableConst, "mark-mutable-const", opts.MarkMutableConst, "emit mutable const wrappers")
 	cmd.Flags().BoolVar(&opts.NoReturnDeadPointer, "no-return-dead-pointer", opts.NoReturnDeadPointer, "forbid returning dead pointers")
 	cmd.Flags().BoolVar(&returnDeadPointerFlag, "return-dead-pointer", false, "allow returning dead pointers")
@@ -222,8 +225,8 @@ func NewRootCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&noSignedCharIndexFlag, "no-signed-char-index", false, "disable signed char index behavior")
 	addBoolPair(cmd, &negBindings, &opts.ForceGlobalsStatic, "force-globals-static", "force static storage for globals and functions")
 	addBoolPair(cmd, &negBindings, &opts.ForceNonUniformArrayInit, "force-non-uniform-arrays", "force non-uniform array initializers")
-	addBoolPair(cmd, &negBindings, &opts.Int128, "int128", "enable __int128 type")
-	addBoolPair(cmd, &negBindings, &opts.UInt128, "uint128", "enable unsigned __int128 type")
+	addBoolPair(cmd, &negBindings, &opts.Int128, "int128", "enable __int128 type (with safe math, needs --runtime inline or write)")
+	addBoolPair(cmd, &negBindings, &opts.UInt128, "uint128", "enable unsigned __int128 type (with safe math, needs --runtime inline or write)")
 	addBoolPair(cmd, &negBindings, &opts.BinaryConstant, "binary-constant", "enable binary constants")
 	addBoolPair(cmd, &negBindings, &opts.MathNoTmp, "math-notmp", "use no-temp safe math wrappers")
 	cmd.Flags().BoolVar(&opts.StrictFloat, "strict-float", opts.StrictFloat, "enforce strict floating-point semantics")
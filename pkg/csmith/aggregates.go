@@ -0,0 +1,268 @@
+package csmith
+
+import (
+	"strings"
+
+	"csmith/pkg/csmith/cast"
+)
+
+// aggregate returns the layout of the struct or union type t, or the zero
+// layout for any other type.
+func (info compositeInfo) aggregate(t CType) structTypeInfo {
+	for i, st := range info.structs {
+		if structType(i).Name == t.Name {
+			return st
+		}
+	}
+	for i, ut := range info.unions {
+		if unionType(i).Name == t.Name {
+			return structTypeInfo(ut)
+		}
+	}
+	return structTypeInfo{}
+}
+
+// fields returns the members of the struct or union type t.
+func (info compositeInfo) fields(t CType) []fieldInfo {
+	return info.aggregate(t).fields
+}
+
+// level returns the nesting depth of aggregate t, or 0 for simple types.
+func (info compositeInfo) level(t CType) int {
+	return info.aggregate(t).level
+}
+
+// packed reports whether aggregate t carries the packed attribute.
+func (info compositeInfo) packed(t CType) bool {
+	return info.aggregate(t).packed
+}
+
+// leafField is a scalar member of an aggregate, reached through a chain of
+// nested members.
+type leafField struct {
+	// path is the dot-separated member chain, e.g. "f1.f0".
+	path  string
+	ctype CType
+	// qual accumulates the qualifiers of the enclosing members.
+	qual     cast.Qual
+	bitfield bool
+	// inUnion marks leaves reached through a union member.
+	inUnion bool
+	// packed marks leaves of a packed aggregate, which may be misaligned.
+	packed bool
+}
+
+// leaves returns the scalar members of t, descending into nested aggregates.
+// With hashed, only the first member of each union is visited, as for
+// hashedFields.
+func (info compositeInfo) leaves(t CType, hashed bool) []leafField {
+	fields := info.fields(t)
+	if hashed {
+		fields = info.hashedFields(t)
+	}
+	var out []leafField
+	for _, f := range fields {
+		if !f.ctype.Aggregate {
+			out = append(out, leafField{path: f.name, ctype: f.ctype, qual: f.qual, bitfield: f.bitfield, inUnion: t.isUnion(), packed: info.packed(t)})
+			continue
+		}
+		for _, l := range info.leaves(f.ctype, hashed) {
+			l.path = f.name + "." + l.path
+			l.qual |= f.qual
+			l.inUnion = l.inUnion || t.isUnion()
+			l.packed = l.packed || info.packed(t)
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// memberPath accesses the member chain path of x.
+func memberPath(x cast.Expr, path string) cast.Expr {
+	for _, name := range strings.Split(path, ".") {
+		x = member(x, name)
+	}
+	return x
+}
+
+// hashedFields returns the members of t folded into checksums. Union members
+// alias, so only the first one is.
+func (info compositeInfo) hashedFields(t CType) []fieldInfo {
+	fields := info.fields(t)
+	if len(fields) > 0 && t.isUnion() {
+		return fields[:1]
+	}
+	return fields
+}
+
+// wholeAssignable reports whether objects of type t may be assigned as a
+// whole; C forbids it when any member, however nested, is const.
+func (info compositeInfo) wholeAssignable(t CType) bool {
+	for _, l := range info.leaves(t, false) {
+		if l.qual&cast.Const != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// writableField returns the first non-const hashed scalar member of t.
+func (info compositeInfo) writableField(t CType) (leafField, bool) {
+	for _, l := range info.leaves(t, true) {
+		if l.qual&cast.Const == 0 {
+			return l, true
+		}
+	}
+	return leafField{}, false
+}
+
+func member(x cast.Expr, name string) cast.Expr {
+	return &cast.Member{X: x, Name: name}
+}
+
+// varCandidates returns the candidates a variable v of type t contributes to
+// scalar expressions: itself, or each member when t is an aggregate.
+func varCandidates(v cast.Expr, t CType, assignable bool, ctx *genContext) []exprVarCandidate {
+	if !t.Aggregate {
+		return []exprVarCandidate{{expr: v, ctype: t, assignable: assignable}}
+	}
+	if ctx == nil {
+		return nil
+	}
+	var out []exprVarCandidate
+	for _, l := range ctx.info.leaves(t, false) {
+		out = append(out, exprVarCandidate{
+			expr:       memberPath(v, l.path),
+			ctype:      l.ctype,
+			assignable: assignable && l.qual&cast.Const == 0,
+		})
+	}
+	return out
+}
+
+// aggregateZeroInit is the initializer of aggregate objects created without
+// drawing random values.
+func aggregateZeroInit() cast.Expr {
+	return &cast.InitList{Elems: []cast.Expr{lit("0")}}
+}
+
+// aggregateTemporary is a zero-valued temporary of aggregate type t, standing
+// in for a missing variable: a compound literal in C, a value-initialized
+// temporary in C++.
+func aggregateTemporary(t CType, opts Options) cast.Expr {
+	if !opts.LangCPP {
+		return &cast.Cast{Type: typeNode(t), X: aggregateZeroInit()}
+	}
+	// C++ names the class without its struct or union keyword.
+	tag := t.Name[strings.IndexByte(t.Name, ' ')+1:]
+	return &cast.Temporary{Type: &cast.Named{Name: tag}, Braced: opts.CPP11}
+}
+
+// aggregateInitExpr initializes every member of a struct, or the first member
+// of a union, with a constant, nesting an initializer list per aggregate
+// member. Bit-fields start at zero so the value always fits their width.
+func aggregateInitExpr(t CType, info compositeInfo, constant func(CType) cast.Expr) cast.Expr {
+	init := &cast.InitList{}
+	for _, f := range info.hashedFields(t) {
+		if f.bitfield {
+			init.Elems = append(init.Elems, lit("0"))
+			continue
+		}
+		if f.ctype.Aggregate {
+			init.Elems = append(init.Elems, aggregateInitExpr(f.ctype, info, constant))
+			continue
+		}
+		init.Elems = append(init.Elems, constant(f.ctype))
+	}
+	if len(init.Elems) == 0 {
+		return aggregateZeroInit()
+	}
+	return init
+}
+
+// mixAggregateIntoX folds the hashed scalar members of v, a value of aggregate type
+// t, into the running checksum local.
+func mixAggregateIntoX(blk *cast.Block, v cast.Expr, t CType, info compositeInfo, opts Options) {
+	for _, l := range info.leaves(t, true) {
+		addStmt(blk, mixIntoX(memberPath(v, l.path), l.ctype, opts))
+	}
+}
+
+// aggregateVars lists the variables of aggregate type t in scope.
+func aggregateVars(t CType, env envInfo, scope scopeInfo, ctx *genContext) []exprVarCandidate {
+	var out []exprVarCandidate
+	for _, g := range mergedGlobals(env, ctx) {
+		if g.ctype.Name == t.Name {
+			out = append(out, exprVarCandidate{expr: ident(g.name), ctype: t, assignable: !g.isConst})
+		}
+	}
+	for _, p := range scope.params {
+		if p.ctype.Name == t.Name {
+			out = append(out, exprVarCandidate{expr: ident(p.name), ctype: t, assignable: true})
+		}
+	}
+	for _, l := range mergedLocals(scope, ctx) {
+		if l.ctype.Name == t.Name {
+			out = append(out, exprVarCandidate{expr: ident(l.name), ctype: t, assignable: true})
+		}
+	}
+	return out
+}
+
+// aggregateExpr builds an expression of struct or union type t: a call to a
+// function returning t (with --return-structs / --return-unions), a whole
+// assignment between two variables of type t, or one such variable, created
+// on demand when none is in scope.
+func aggregateExpr(t CType, er *exprRand, opts Options, env envInfo, scope scopeInfo, depth int, ctx *genContext) cast.Expr {
+	if returnsAllowed(t, opts) && depth+2 <= maxExprDepth(opts) && er.pick(4) == 0 {
+		snap := takeGenSnapshot(ctx)
+		if c, ok := buildFunctionCallExpr(t, er, opts, env, scope, depth, ctx); ok {
+			return c
+		}
+		restoreGenSnapshot(ctx, snap)
+	}
+	vars := aggregateVars(t, env, scope, ctx)
+	if len(vars) == 0 {
+		g, ok := createOnDemandGlobalFromER(er, opts, t, ctx)
+		if !ok {
+			return aggregateTemporary(t, opts)
+		}
+		vars = append(vars, g)
+	}
+	src := vars[int(er.pick(uint32(len(vars))))]
+	if !opts.EmbeddedAssigns || ctx == nil || !ctx.info.wholeAssignable(t) || er.pick(4) != 0 {
+		return src.expr
+	}
+	var dsts []exprVarCandidate
+	for _, v := range vars {
+		if v.assignable && v.expr != src.expr {
+			dsts = append(dsts, v)
+		}
+	}
+	if len(dsts) == 0 {
+		return src.expr
+	}
+	dst := dsts[int(er.pick(uint32(len(dsts))))]
+	return paren(assign(dst.expr, "=", src.expr))
+}
+
+// returnsAllowed reports whether functions may return values of type t.
+func returnsAllowed(t CType, opts Options) bool {
+	if !t.Aggregate {
+		return true
+	}
+	if t.isUnion() {
+		return opts.ReturnUnions
+	}
+	return opts.ReturnStructs
+}
+
+// aggregateMemberValue reads a member of x, a value of aggregate type from,
+// converted to to.
+func aggregateMemberValue(from, to CType, x cast.Expr, info compositeInfo, opts Options) cast.Expr {
+	leaves := info.leaves(from, true)
+	if len(leaves) == 0 {
+		return castExpr(to, lit("0"))
+	}
+	return convertExpr(leaves[0].ctype, to, memberPath(x, leaves[0].path), opts)
+}
@@ -0,0 +1,284 @@
+package csmith
+
+import (
+	"fmt"
+
+	"csmith/pkg/csmith/cast"
+)
+
+// arrayInfo describes a (possibly multi-dimensional) array variable. ctype is
+// the scalar type an element access yields: the element type itself, the
+// selected field of an aggregate element, or the target of a pointer element.
+type arrayInfo struct {
+	name  string
+	ctype CType
+	dims  []int
+	// elem is the declared element type when it differs from ctype.
+	elem cast.Type
+	// field is the member path accessed through each element; hashed lists
+	// every scalar member folded into the checksum.
+	field  string
+	hashed []leafField
+	// pointer marks arrays of pointers, dereferenced on access.
+	pointer bool
+}
+
+// size returns the total number of elements.
+func (a arrayInfo) size() int {
+	n := 1
+	for _, d := range a.dims {
+		n *= d
+	}
+	return n
+}
+
+// declType returns the declared array type: "T name[d0][d1]...".
+func (a arrayInfo) declType() cast.Type {
+	t := a.elem
+	if t == nil {
+		t = typeNode(a.ctype)
+	}
+	for i := len(a.dims) - 1; i >= 0; i-- {
+		t = &cast.Array{Elem: t, Len: a.dims[i]}
+	}
+	return t
+}
+
+// element indexes the array with idx (one subscript per dimension).
+func (a arrayInfo) element(idx []cast.Expr) cast.Expr {
+	var e cast.Expr = ident(a.name)
+	for _, i := range idx {
+		e = index(e, i)
+	}
+	return e
+}
+
+// access returns the scalar lvalue of the element at idx.
+func (a arrayInfo) access(idx []cast.Expr) cast.Expr {
+	e := a.element(idx)
+	switch {
+	case a.pointer:
+		return deref(e)
+	case a.field != "":
+		return memberPath(e, a.field)
+	}
+	return e
+}
+
+// randomAccess accesses an element at constant in-bounds indices.
+func (a arrayInfo) randomAccess(pick func(uint32) uint32) cast.Expr {
+	idx := make([]cast.Expr, len(a.dims))
+	for i, d := range a.dims {
+		idx[i] = lit("%d", int(pick(uint32(d))))
+	}
+	return a.access(idx)
+}
+
+// leaf returns the aggregate member accessed through each element.
+func (a arrayInfo) leaf() (leafField, bool) {
+	for _, l := range a.hashed {
+		if l.path == a.field {
+			return l, true
+		}
+	}
+	return leafField{}, false
+}
+
+// loopVar names the induction variable of the k-th nested array loop.
+func loopVar(k int) string {
+	const names = "ijklmn"
+	if k < len(names) {
+		return names[k : k+1]
+	}
+	return fmt.Sprintf("i_%d", k)
+}
+
+// randomArrayDims mirrors ArrayVariable::CreateArrayVariable: up to
+// MaxArrayDim dimensions of 1..MaxArrayLenPerDim elements, stopping before the
+// total element count would exceed MaxArrayLength.
+func randomArrayDims(r *rng, opts Options) []int {
+	n := 1 + int(r.upto(uint32(max(1, opts.MaxArrayDim))))
+	dims := make([]int, 0, n)
+	total := 1
+	for i := 0; i < n; i++ {
+		d := 1 + int(r.upto(uint32(max(1, opts.MaxArrayLenPerDim))))
+		if total*d > max(1, opts.MaxArrayLength) {
+			break
+		}
+		dims = append(dims, d)
+		total *= d
+	}
+	if len(dims) == 0 {
+		dims = append(dims, 1)
+	}
+	return dims
+}
+
+func mergedArrays(env envInfo, ctx *genContext) []arrayInfo {
+	if ctx == nil || ctx.state == nil || len(ctx.state.dynArrays) == 0 {
+		return env.arrays
+	}
+	out := make([]arrayInfo, 0, len(env.arrays)+len(ctx.state.dynArrays))
+	out = append(out, env.arrays...)
+	out = append(out, ctx.state.dynArrays...)
+	return out
+}
+
+// createArrayGlobal materializes a new array of t in the simplified backend.
+// Besides plain scalars the elements may be aggregates, accessed through a
+// writable field, or pointers to a fresh scalar global of type t.
+func createArrayGlobal(r *rng, opts Options, t CType, dims []int, ctx *genContext) (exprVarCandidate, bool) {
+	if ctx == nil || ctx.state == nil {
+		return exprVarCandidate{}, false
+	}
+	a := arrayInfo{ctype: t, dims: dims}
+	var init cast.Expr = &cast.InitList{Elems: []cast.Expr{lit("0")}}
+	switch kind := r.upto(10); {
+	case kind == 0:
+		info := ctx.state.info
+		count := len(info.structs) + len(info.unions)
+		if count == 0 {
+			break
+		}
+		pick := int(r.upto(uint32(count)))
+		agg := structType(pick)
+		if pick >= len(info.structs) {
+			agg = unionType(pick - len(info.structs))
+		}
+		// Union members alias; only the first one of each union is hashed,
+		// and so accessed.
+		a.hashed = info.leaves(agg, true)
+		for _, l := range a.hashed {
+			if l.qual&cast.Const == 0 {
+				a.ctype, a.field = l.ctype, l.path
+				break
+			}
+		}
+		if a.field == "" {
+			a.hashed = nil
+			break
+		}
+		a.elem = typeNode(agg)
+	case kind == 1 && opts.Pointers:
+		target, ok := createLocalPathGlobalDirect(opts, t, ctx)
+		if !ok {
+			break
+		}
+		a.pointer = true
+		a.elem = &cast.Pointer{Elem: typeNode(t)}
+		elems := make([]cast.Expr, a.size())
+		for i := range elems {
+			elems[i] = addrOf(target.expr)
+		}
+		init = &cast.InitList{Elems: elems}
+	}
+	id := ctx.state.nextGlobalID
+	ctx.state.nextGlobalID = id + 1
+	a.name = fmt.Sprintf("g_%d", id)
+	ctx.state.lateGlobals = append(ctx.state.lateGlobals, &cast.VarDecl{
+		Storage: "static",
+		Type:    a.declType(),
+		Name:    a.name,
+		Init:    init,
+	})
+	ctx.state.dynArrays = append(ctx.state.dynArrays, a)
+	return exprVarCandidate{expr: a.randomAccess(r.upto), ctype: a.ctype, assignable: true}, true
+}
+
+// emitArrayMutation mirrors upstream StatementArrayOp: a loop nest walks every
+// element of a randomly chosen array and folds a random value into it, reading
+// up to MaxArrayNumInLoop-1 further arrays through the same induction variables.
+func emitArrayMutation(blk *cast.Block, r *rng, opts Options, env envInfo, scope scopeInfo, ctx *genContext) {
+	arrays := mergedArrays(env, ctx)
+	if !opts.Arrays || len(arrays) == 0 {
+		emitArithmeticMutation(blk, r, opts)
+		return
+	}
+	ai := arrays[int(r.upto(uint32(len(arrays))))]
+	idx := make([]cast.Expr, len(ai.dims))
+	for k := range ai.dims {
+		idx[k] = ident(loopVar(k))
+	}
+	elem := ai.access(idx)
+
+	rhs := randomTypedExpr(ai.ctype, r, opts, env, scope, ctx)
+	others := int(r.upto(uint32(max(1, opts.MaxArrayNumInLoop))))
+	for n := 0; n < others; n++ {
+		o := arrays[int(r.upto(uint32(len(arrays))))]
+		oidx := make([]cast.Expr, len(o.dims))
+		for k, d := range o.dims {
+			switch {
+			case k >= len(ai.dims):
+				oidx[k] = lit("0")
+			case ai.dims[k] <= d:
+				oidx[k] = ident(loopVar(k))
+			default:
+				oidx[k] = binary(ident(loopVar(k)), "%", lit("%d", d))
+			}
+		}
+		rhs = binaryOpExpr(ai.ctype, "^", rhs, convertExpr(o.ctype, ai.ctype, o.access(oidx), opts), opts)
+	}
+
+	body := &cast.Block{}
+	addStmt(body, xorAssign(elem, ai.ctype, rhs, opts))
+	if opts.EmbeddedAssigns {
+		one := castExpr(ai.ctype, lit("1u"))
+		u32 := CType{Name: "uint32_t", Signed: false, Bits: 32}
+		addStmt(body, exprStmt(assign(ident("x"), "=", convertExpr(ai.ctype, u32, paren(assign(elem, "=", safeAddExpr(ai.ctype, elem, one, opts))), opts))))
+	}
+	addStmt(body, mixIntoX(elem, ai.ctype, opts))
+	addStmt(blk, arrayLoopNest(ai.dims, body))
+
+	if ctx != nil {
+		first := make([]cast.Expr, len(ai.dims))
+		for k := range first {
+			first[k] = lit("0")
+		}
+		c := exprVarCandidate{expr: ai.access(first), ctype: ai.ctype, assignable: true}
+		ctx.mustUse = &c
+	}
+}
+
+// arrayLoopNest wraps body in one counted loop per dimension, the outermost
+// loop iterating the first dimension.
+func arrayLoopNest(dims []int, body cast.Stmt) cast.Stmt {
+	s := body
+	for k := len(dims) - 1; k >= 0; k-- {
+		v := ident(loopVar(k))
+		s = &cast.For{
+			Init: &cast.VarDecl{Type: &cast.Named{Name: "int"}, Name: loopVar(k), Init: lit("0")},
+			Cond: binary(v, "<", lit("%d", dims[k])),
+			Post: &cast.Postfix{X: v, Op: "++"},
+			Body: s,
+		}
+	}
+	return s
+}
+
+// hashArray folds every element of a into the checksum, as upstream does for
+// array variables, printing the indices along with each value.
+func hashArray(a arrayInfo, crc func(v cast.Expr, t CType, name string) cast.Stmt) cast.Stmt {
+	if a.pointer {
+		// Pointer values are not hashed; their targets are.
+		return nil
+	}
+	idx := make([]cast.Expr, len(a.dims))
+	name := a.name
+	format := "index = "
+	for k := range a.dims {
+		idx[k] = ident(loopVar(k))
+		name += "[" + loopVar(k) + "]"
+		format += "[%d]"
+	}
+	body := &cast.Block{}
+	if a.field == "" {
+		addStmt(body, crc(a.element(idx), a.ctype, name))
+	} else {
+		for _, l := range a.hashed {
+			addStmt(body, crc(memberPath(a.element(idx), l.path), l.ctype, name+"."+l.path))
+		}
+	}
+	args := append([]cast.Expr{lit("%q", format+"\n")}, idx...)
+	addStmt(body, &cast.If{Cond: ident("print_hash_value"), Then: exprStmt(call("printf", args...))})
+	return arrayLoopNest(a.dims, body)
+}
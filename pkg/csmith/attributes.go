@@ -0,0 +1,392 @@
+package csmith
+
+import (
+	"strings"
+
+	"csmith/pkg/csmith/cast"
+)
+
+// attribute is an entry of an attribute catalog. When drawn, one of its
+// choices is attached; alternatives that conflict with each other (hot and
+// cold, or several alignments) share an entry.
+type attribute struct {
+	choices []string
+}
+
+// Every catalog entry is semantically safe: it may change how a program is
+// compiled, but not what a well-defined program computes.
+var (
+	functionAttributes = []attribute{
+		{choices: []string{"hot", "cold"}},
+		{choices: []string{"unused"}},
+		{choices: []string{"used"}},
+		{choices: []string{"noipa"}},
+		{choices: []string{"noclone"}},
+		{choices: []string{"flatten"}},
+		{choices: []string{`section(".text.csmith")`}},
+	}
+	typeAttributes = []attribute{
+		{choices: []string{"packed"}},
+		{choices: []string{"aligned(8)", "aligned(16)", "aligned(32)"}},
+		{choices: []string{"may_alias"}},
+		{choices: []string{"unused"}},
+	}
+	labelAttributes = []attribute{
+		{choices: []string{"unused"}},
+		{choices: []string{"hot", "cold"}},
+	}
+	variableAttributes = []attribute{
+		{choices: []string{"unused"}},
+		{choices: []string{"used"}},
+		{choices: []string{"aligned(8)", "aligned(16)", "aligned(32)"}},
+		{choices: []string{`section(".data.csmith")`}},
+	}
+)
+
+// drawAttributes flips prob once per catalog entry and collects the drawn
+// choices that keep accepts. Rejected choices still consume their draws, so
+// the filter never shifts the random stream.
+func drawAttributes(r *rng, prob uint32, catalog []attribute, keep func(string) bool) []string {
+	var out []string
+	for _, a := range catalog {
+		if !r.flipcoin(prob) {
+			continue
+		}
+		c := a.choices[int(r.upto(uint32(len(a.choices))))]
+		if keep == nil || keep(c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// drawTypeAttributes picks the attributes of a new struct or union type.
+// packed is only offered when packed structs are enabled, and CompCert only
+// gets alignments.
+func drawTypeAttributes(r *rng, opts Options) []string {
+	if !opts.TypeAttributes {
+		return nil
+	}
+	return drawAttributes(r, opts.prob(probTypeAttr), typeAttributes, func(a string) bool {
+		if opts.CComp {
+			return strings.HasPrefix(a, "aligned(")
+		}
+		return a != "packed" || opts.PackedStruct
+	})
+}
+
+func hasAttribute(attrs []string, name string) bool {
+	for _, a := range attrs {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// drawProgramAttributes attaches attributes to the globals, and to the
+// functions, labels and locals of the generated definitions, once the whole
+// program exists. Functions are visited from the last one back, so every
+// callee is classified before its callers.
+func (s *functionFlowState) drawProgramAttributes(r *rng) {
+	opts := s.opts
+	if opts.VariableAttributes {
+		for _, d := range s.lateGlobals {
+			v, ok := d.(*cast.VarDecl)
+			if !ok {
+				continue
+			}
+			writable := !strings.Contains(cast.TypeName(v.Type), "const")
+			v.Attrs = drawAttributes(r, opts.prob(probVarAttr), variableAttributes, func(a string) bool {
+				// Read-only and writable objects cannot share a section.
+				return !strings.HasPrefix(a, "section") || writable
+			})
+		}
+	}
+	volatile := s.volatileGlobals()
+	purities := map[string]purity{}
+	// Per-site wrappers record their site in a global.
+	wrappers := constFunc
+	if opts.identifiesWrappers() {
+		wrappers = impure
+	}
+	for i := len(s.defs) - 1; i >= 0; i-- {
+		def := s.defs[i]
+		if def == nil {
+			continue
+		}
+		if opts.FunctionAttributes {
+			fn := &s.funcs[i]
+			forced := hasAttribute(fn.attrs, "always_inline")
+			fn.attrs = append(fn.attrs, drawAttributes(r, opts.prob(probFuncAttr), functionAttributes, func(a string) bool {
+				// noipa and noclone contradict a forced inline.
+				return !forced || (a != "noipa" && a != "noclone")
+			})...)
+			p := bodyPurity(def, purities, volatile, wrappers)
+			purities[fn.name] = p
+			if p != impure && r.flipcoin(opts.prob(probFuncAttr)) {
+				fn.attrs = append(fn.attrs, p.String())
+			}
+			def.Attrs = fn.attrs
+		}
+		if opts.LabelAttributes || opts.VariableAttributes {
+			drawBodyAttributes(def.Body, r, opts)
+		}
+	}
+}
+
+// drawBodyAttributes attaches attributes to the labels and local variables of
+// blk, in statement order.
+func drawBodyAttributes(blk *cast.Block, r *rng, opts Options) {
+	var visit func(s cast.Stmt)
+	visit = func(s cast.Stmt) {
+		switch s := s.(type) {
+		case *cast.Block:
+			for _, st := range s.Stmts {
+				visit(st)
+			}
+		case *cast.Labeled:
+			if opts.LabelAttributes {
+				s.Attrs = drawAttributes(r, opts.prob(probLabelAttr), labelAttributes, nil)
+			}
+			visit(s.Stmt)
+		case *cast.VarDecl:
+			if opts.VariableAttributes {
+				s.Attrs = drawAttributes(r, opts.prob(probVarAttr), variableAttributes, func(a string) bool {
+					// Only objects with static storage can be placed in a
+					// section or kept by "used".
+					return a != "used" && !strings.HasPrefix(a, "section")
+				})
+			}
+		case *cast.If:
+			visit(s.Then)
+			if s.Else != nil {
+				visit(s.Else)
+			}
+		case *cast.For:
+			if s.Prologue != nil {
+				visit(s.Prologue)
+			}
+			visit(s.Body)
+		}
+	}
+	visit(blk)
+}
+
+// purity classifies a function for the pure and const attributes.
+type purity int
+
+const (
+	impure purity = iota
+	// pureFunc functions have no side effects but may read globals.
+	pureFunc
+	// constFunc functions only depend on their arguments.
+	constFunc
+)
+
+func (p purity) String() string {
+	switch p {
+	case pureFunc:
+		return "pure"
+	case constFunc:
+		return "const"
+	}
+	return ""
+}
+
+// volatileGlobals returns the globals that cannot be read without a side
+// effect: volatile objects and, conservatively, every aggregate global once
+// any aggregate type has a volatile member.
+func (s *functionFlowState) volatileGlobals() map[string]bool {
+	volatileFields := false
+	for i := range s.info.structs {
+		for _, l := range s.info.leaves(structType(i), false) {
+			volatileFields = volatileFields || l.qual&cast.Volatile != 0
+		}
+	}
+	for i := range s.info.unions {
+		for _, l := range s.info.leaves(unionType(i), false) {
+			volatileFields = volatileFields || l.qual&cast.Volatile != 0
+		}
+	}
+	out := map[string]bool{}
+	for _, d := range s.lateGlobals {
+		v, ok := d.(*cast.VarDecl)
+		if !ok {
+			continue
+		}
+		tn := cast.TypeName(v.Type)
+		aggregate := strings.Contains(tn, "struct ") || strings.Contains(tn, "union ")
+		if strings.Contains(tn, "volatile") || (aggregate && volatileFields) {
+			out[v.Name] = true
+		}
+	}
+	return out
+}
+
+// bodyPurity conservatively classifies def. Writing anything but a local,
+// reading a volatile global, dereferencing a pointer, holding a pointer local
+// or calling anything but a pure function, a safe-math wrapper or a
+// side-effect-free builtin makes a function impure; reading any global makes
+// it pure at best. callees holds the class of the functions def may call, and
+// wrappers that of the safe-math wrappers.
+func bodyPurity(def *cast.FuncDef, callees map[string]purity, volatile map[string]bool, wrappers purity) purity {
+	locals := map[string]bool{}
+	result := constFunc
+	demote := func(p purity) {
+		if p < result {
+			result = p
+		}
+	}
+	declare := func(name string, t cast.Type) {
+		tn := cast.TypeName(t)
+		if strings.Contains(tn, "*") || strings.Contains(tn, "volatile") {
+			demote(impure)
+		}
+		locals[name] = true
+	}
+	for _, p := range def.Params {
+		declare(p.Name, p.Type)
+	}
+	var expr func(e cast.Expr)
+	write := func(lhs cast.Expr) {
+		root := lhs
+	walk:
+		for {
+			switch x := root.(type) {
+			case *cast.Paren:
+				root = x.X
+			case *cast.Member:
+				if x.Arrow {
+					demote(impure)
+				}
+				root = x.X
+			case *cast.Index:
+				expr(x.Index)
+				root = x.X
+			default:
+				break walk
+			}
+		}
+		if id, ok := root.(*cast.Ident); !ok || !locals[id.Name] {
+			demote(impure)
+		}
+	}
+	expr = func(e cast.Expr) {
+		switch e := e.(type) {
+		case *cast.Ident:
+			if !locals[e.Name] {
+				demote(pureFunc)
+				if volatile[e.Name] {
+					demote(impure)
+				}
+			}
+		case *cast.Paren:
+			expr(e.X)
+		case *cast.Cast:
+			expr(e.X)
+		case *cast.Unary:
+			switch e.Op {
+			case "*":
+				demote(impure)
+			case "++", "--":
+				write(e.X)
+			}
+			expr(e.X)
+		case *cast.Postfix:
+			write(e.X)
+			expr(e.X)
+		case *cast.Binary:
+			expr(e.X)
+			expr(e.Y)
+		case *cast.Assign:
+			write(e.LHS)
+			if e.Op != "=" {
+				expr(e.LHS)
+			}
+			expr(e.RHS)
+		case *cast.Comma:
+			expr(e.X)
+			expr(e.Y)
+		case *cast.Call:
+			demote(calleePurity(e.Fun, callees, wrappers))
+			for _, a := range e.Args {
+				expr(a)
+			}
+		case *cast.Index:
+			expr(e.X)
+			expr(e.Index)
+		case *cast.Member:
+			if e.Arrow {
+				demote(impure)
+			}
+			expr(e.X)
+		case *cast.InitList:
+			for _, x := range e.Elems {
+				expr(x)
+			}
+		}
+	}
+	var stmt func(s cast.Stmt)
+	stmt = func(s cast.Stmt) {
+		switch s := s.(type) {
+		case *cast.Block:
+			for _, st := range s.Stmts {
+				stmt(st)
+			}
+		case *cast.VarDecl:
+			declare(s.Name, s.Type)
+			if s.Init != nil {
+				expr(s.Init)
+			}
+		case *cast.ExprStmt:
+			expr(s.X)
+		case *cast.If:
+			expr(s.Cond)
+			stmt(s.Then)
+			if s.Else != nil {
+				stmt(s.Else)
+			}
+		case *cast.For:
+			if s.Init != nil {
+				stmt(s.Init)
+			}
+			if s.Cond != nil {
+				expr(s.Cond)
+			}
+			if s.Post != nil {
+				expr(s.Post)
+			}
+			if s.Prologue != nil {
+				stmt(s.Prologue)
+			}
+			stmt(s.Body)
+		case *cast.Return:
+			if s.X != nil {
+				expr(s.X)
+			}
+		case *cast.Labeled:
+			stmt(s.Stmt)
+		}
+	}
+	stmt(def.Body)
+	return result
+}
+
+// calleePurity classifies the function a call invokes.
+func calleePurity(fun cast.Expr, callees map[string]purity, wrappers purity) purity {
+	id, ok := fun.(*cast.Ident)
+	if !ok {
+		return impure
+	}
+	if p, ok := callees[id.Name]; ok {
+		return p
+	}
+	switch {
+	case strings.HasPrefix(id.Name, "safe_"):
+		return wrappers
+	case strings.HasPrefix(id.Name, "__builtin_") && !strings.HasSuffix(id.Name, "_overflow"):
+		return constFunc
+	}
+	return impure
+}
@@ -0,0 +1,69 @@
+package csmith
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"csmith/pkg/csmith/cast"
+)
+
+func TestCCompAttributes(t *testing.T) {
+	attr := regexp.MustCompile(`__attribute__\(\((.*?)\)\)[^)]`)
+	for seed := uint64(1); seed <= 20; seed++ {
+		opts := Defaults()
+		opts.Seed = seed
+		opts.CComp = true
+		opts.FunctionAttributes = true
+		opts.TypeAttributes = true
+		opts.LabelAttributes = true
+		opts.VariableAttributes = true
+		program, err := Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range attr.FindAllStringSubmatch(program, -1) {
+			if !strings.HasPrefix(m[1], "aligned(") {
+				t.Fatalf("seed %d: CompCert program carries __attribute__((%s))", seed, m[1])
+			}
+		}
+	}
+}
+
+func TestBodyPurity(t *testing.T) {
+	u32 := &cast.Named{Name: "uint32_t"}
+	fn := func(stmts ...cast.Stmt) *cast.FuncDef {
+		return &cast.FuncDef{
+			FuncDecl: cast.FuncDecl{Ret: u32, Name: "func_2", Params: []*cast.Param{{Type: u32, Name: "p_1"}}},
+			Body:     &cast.Block{Stmts: stmts},
+		}
+	}
+	ret := func(e cast.Expr) cast.Stmt { return &cast.Return{X: e} }
+	local := &cast.VarDecl{Type: u32, Name: "l_1", Init: ident("p_1")}
+	callees := map[string]purity{"func_3": pureFunc, "func_4": impure}
+	volatile := map[string]bool{"g_2": true}
+	for _, tc := range []struct {
+		name     string
+		def      *cast.FuncDef
+		wrappers purity
+		want     purity
+	}{
+		{"arguments only", fn(local, exprStmt(assign(ident("l_1"), "+=", lit("1"))), ret(ident("l_1"))), constFunc, constFunc},
+		{"reads a global", fn(ret(binary(ident("p_1"), "+", ident("g_1")))), constFunc, pureFunc},
+		{"reads a volatile global", fn(ret(ident("g_2"))), constFunc, impure},
+		{"writes a global", fn(exprStmt(assign(ident("g_1"), "=", ident("p_1"))), ret(lit("0"))), constFunc, impure},
+		{"increments a global", fn(exprStmt(&cast.Postfix{X: ident("g_1"), Op: "++"}), ret(lit("0"))), constFunc, impure},
+		{"dereferences", fn(ret(deref(ident("p_1")))), constFunc, impure},
+		{"pointer local", fn(&cast.VarDecl{Type: &cast.Pointer{Elem: u32}, Name: "l_2"}, ret(lit("0"))), constFunc, impure},
+		{"calls a pure function", fn(ret(call("func_3", ident("p_1")))), constFunc, pureFunc},
+		{"calls an impure function", fn(ret(call("func_4", ident("p_1")))), constFunc, impure},
+		{"calls a builtin", fn(ret(call("__builtin_popcount", ident("p_1")))), constFunc, constFunc},
+		{"calls an overflow builtin", fn(ret(call("__builtin_add_overflow", ident("p_1"), ident("p_1"), addrOf(ident("g_1"))))), constFunc, impure},
+		{"calls a wrapper", fn(ret(call("safe_add_func_uint32_t_u_u", ident("p_1"), lit("1")))), constFunc, constFunc},
+		{"calls a counting wrapper", fn(ret(call("safe_add_func_uint32_t_u_u", ident("p_1"), lit("1")))), impure, impure},
+	} {
+		if got := bodyPurity(tc.def, callees, volatile, tc.wrappers); got != tc.want {
+			t.Errorf("%s: bodyPurity = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package csmith
+
+import (
+	"fmt"
+	"strings"
+
+	"csmith/pkg/csmith/cast"
+)
+
+// Builtin kinds accepted by --enable-builtin-kinds and --disable-builtin-kinds.
+const (
+	builtinKindBits     = "bits"
+	builtinKindBswap    = "bswap"
+	builtinKindOverflow = "overflow"
+	builtinKindExpect   = "expect"
+)
+
+var builtinKinds = []string{builtinKindBits, builtinKindBswap, builtinKindOverflow, builtinKindExpect}
+
+// builtinFunc is a GCC/Clang builtin generated code may call.
+type builtinFunc struct {
+	kind   string
+	name   string
+	ret    CType
+	params []CType
+	// guard rewrites the arguments of builtins that are undefined for some
+	// inputs, e.g. __builtin_ctz(0).
+	guard func(args []cast.Expr) []cast.Expr
+	// overflow marks the __builtin_*_overflow family, which stores the
+	// wrapped result through a pointer to params[0].
+	overflow bool
+}
+
+func (b builtinFunc) longLong() bool {
+	for _, p := range append([]CType{b.ret}, b.params...) {
+		if p.Bits == 64 {
+			return true
+		}
+	}
+	return false
+}
+
+var builtinFuncs = func() []builtinFunc {
+	i32 := CType{Name: "int32_t", Signed: true, Bits: 32}
+	u16 := CType{Name: "uint16_t", Signed: false, Bits: 16}
+	u32 := CType{Name: "uint32_t", Signed: false, Bits: 32}
+	i64 := CType{Name: "int64_t", Signed: true, Bits: 64}
+	u64 := CType{Name: "uint64_t", Signed: false, Bits: 64}
+	// orBits sets a bit the builtin does not look at first, so a zero
+	// argument yields the result for that bit instead of being undefined.
+	orBits := func(mask string) func([]cast.Expr) []cast.Expr {
+		return func(args []cast.Expr) []cast.Expr {
+			return []cast.Expr{paren(binary(paren(args[0]), "|", lit("%s", mask)))}
+		}
+	}
+	fns := []builtinFunc{
+		{kind: builtinKindBits, name: "__builtin_popcount", ret: i32, params: []CType{u32}},
+		{kind: builtinKindBits, name: "__builtin_popcountll", ret: i32, params: []CType{u64}},
+		{kind: builtinKindBits, name: "__builtin_parity", ret: i32, params: []CType{u32}},
+		{kind: builtinKindBits, name: "__builtin_parityll", ret: i32, params: []CType{u64}},
+		{kind: builtinKindBits, name: "__builtin_ffs", ret: i32, params: []CType{i32}},
+		{kind: builtinKindBits, name: "__builtin_clrsb", ret: i32, params: []CType{i32}},
+		{kind: builtinKindBits, name: "__builtin_clz", ret: i32, params: []CType{u32}, guard: orBits("1u")},
+		{kind: builtinKindBits, name: "__builtin_clzll", ret: i32, params: []CType{u64}, guard: orBits("1ull")},
+		{kind: builtinKindBits, name: "__builtin_ctz", ret: i32, params: []CType{u32}, guard: orBits("0x80000000u")},
+		{kind: builtinKindBits, name: "__builtin_ctzll", ret: i32, params: []CType{u64}, guard: orBits("0x8000000000000000ull")},
+		{kind: builtinKindBswap, name: "__builtin_bswap16", ret: u16, params: []CType{u16}},
+		{kind: builtinKindBswap, name: "__builtin_bswap32", ret: u32, params: []CType{u32}},
+		{kind: builtinKindBswap, name: "__builtin_bswap64", ret: u64, params: []CType{u64}},
+		{kind: builtinKindExpect, name: "__builtin_expect", ret: i32, params: []CType{i32, i32}},
+	}
+	for _, op := range []string{"add", "sub", "mul"} {
+		for _, t := range []CType{i32, u32, i64, u64} {
+			fns = append(fns, builtinFunc{
+				kind:     builtinKindOverflow,
+				name:     fmt.Sprintf("__builtin_%s_overflow", op),
+				ret:      i32,
+				params:   []CType{t, t},
+				overflow: true,
+			})
+		}
+	}
+	return fns
+}()
+
+// parseBuiltinKinds splits a comma-separated kind list, rejecting unknown
+// kinds.
+func parseBuiltinKinds(list string) ([]string, error) {
+	var kinds []string
+	for _, k := range strings.Split(list, ",") {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		known := false
+		for _, b := range builtinKinds {
+			known = known || b == k
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown builtin kind %q (want one of %s)", k, strings.Join(builtinKinds, ","))
+		}
+		kinds = append(kinds, k)
+	}
+	return kinds, nil
+}
+
+// resolveBuiltinKinds returns the kinds builtins may be drawn from: every
+// kind (nil), unless --enable-builtin-kinds lists others or
+// --disable-builtin-kinds lists it. Invalid lists are reported by Validate.
+func resolveBuiltinKinds(opts Options) map[string]bool {
+	enabled, _ := parseBuiltinKinds(opts.EnableBuiltinKinds)
+	disabled, _ := parseBuiltinKinds(opts.DisableBuiltinKinds)
+	if len(enabled) == 0 && len(disabled) == 0 {
+		return nil
+	}
+	kinds := make(map[string]bool)
+	for _, k := range builtinKinds {
+		kinds[k] = len(enabled) == 0
+	}
+	for _, k := range enabled {
+		kinds[k] = true
+	}
+	for _, k := range disabled {
+		kinds[k] = false
+	}
+	return kinds
+}
+
+// enabledBuiltins groups the builtins that may be called by kind, in
+// builtinKinds order, leaving out kinds with none.
+func enabledBuiltins(opts Options) [][]builtinFunc {
+	var out [][]builtinFunc
+	for _, kind := range builtinKinds {
+		if opts.builtinKinds != nil && !opts.builtinKinds[kind] {
+			continue
+		}
+		var fns []builtinFunc
+		for _, b := range builtinFuncs {
+			if b.kind == kind && (opts.LongLong || !b.longLong()) {
+				fns = append(fns, b)
+			}
+		}
+		if len(fns) > 0 {
+			out = append(out, fns)
+		}
+	}
+	return out
+}
+
+// buildBuiltinCallExpr calls a random enabled builtin with random arguments
+// and converts its result to t. The kind is drawn first, so the twelve
+// overflow builtins do not crowd out the others. Overflow builtins store their
+// result in a fresh global, which the checksum then covers.
+func buildBuiltinCallExpr(t CType, er *exprRand, opts Options, env envInfo, scope scopeInfo, depth int, ctx *genContext) (cast.Expr, bool) {
+	kinds := enabledBuiltins(opts)
+	if len(kinds) == 0 || ctx == nil || ctx.state == nil {
+		return nil, false
+	}
+	fns := kinds[int(er.pick(uint32(len(kinds))))]
+	b := fns[int(er.pick(uint32(len(fns))))]
+	args := make([]cast.Expr, 0, len(b.params)+1)
+	for _, p := range b.params {
+		args = append(args, randomParamExprDepth(p, er, opts, env, scope, depth+1, ctx))
+	}
+	if b.guard != nil {
+		args = b.guard(args)
+	}
+	if b.overflow {
+		args = append(args, addrOf(ident(scratchGlobal(b.params[0], ctx))))
+	}
+	return convertExpr(b.ret, t, call(b.name, args...), opts), true
+}
+
+// scratchGlobal declares an unqualified, zero-initialized global of type t.
+func scratchGlobal(t CType, ctx *genContext) string {
+	id := ctx.state.nextGlobalID
+	ctx.state.nextGlobalID = id + 1
+	name := fmt.Sprintf("g_%d", id)
+	ctx.state.lateGlobals = append(ctx.state.lateGlobals, &cast.VarDecl{
+		Storage: "static",
+		Type:    typeNode(t),
+		Name:    name,
+		Init:    castExpr(t, lit("0")),
+	})
+	ctx.state.dynGlobals = append(ctx.state.dynGlobals, globalInfo{name: name, ctype: t})
+	return name
+}
@@ -0,0 +1,45 @@
+package csmith
+
+import "testing"
+
+func TestEnabledBuiltinKinds(t *testing.T) {
+	kindsOf := func(enable, disable string, longLong bool) []string {
+		opts := Defaults()
+		opts.LongLong = longLong
+		opts.EnableBuiltinKinds, opts.DisableBuiltinKinds = enable, disable
+		opts.builtinKinds = resolveBuiltinKinds(opts)
+		var out []string
+		for _, fns := range enabledBuiltins(opts) {
+			for _, b := range fns {
+				if b.kind != fns[0].kind {
+					t.Fatalf("%s grouped with %s", b.name, fns[0].name)
+				}
+				if b.longLong() && !longLong {
+					t.Fatalf("%s offered without long long", b.name)
+				}
+			}
+			out = append(out, fns[0].kind)
+		}
+		return out
+	}
+	for _, tc := range []struct {
+		enable, disable string
+		want            []string
+	}{
+		{"", "", builtinKinds},
+		{"bswap, expect", "", []string{"bswap", "expect"}},
+		{"", "overflow", []string{"bits", "bswap", "expect"}},
+		{"bits,overflow", "overflow", []string{"bits"}},
+	} {
+		got := kindsOf(tc.enable, tc.disable, true)
+		if len(got) != len(tc.want) {
+			t.Fatalf("enable %q disable %q: kinds %v, want %v", tc.enable, tc.disable, got, tc.want)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("enable %q disable %q: kinds %v, want %v", tc.enable, tc.disable, got, tc.want)
+			}
+		}
+	}
+	kindsOf("", "", false)
+}
@@ -0,0 +1,380 @@
+// Package cast is a small typed syntax tree for the C programs produced by
+// the generator. Nodes keep the exact shape the generator builds (including
+// explicit parentheses), so printing a tree reproduces the generated text.
+package cast
+
+// Node is any element of a C translation unit.
+type Node interface {
+	node()
+}
+
+// Type is a C type as written in declarations and casts.
+type Type interface {
+	Node
+	typeNode()
+}
+
+// Expr is a C expression.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// Stmt is a C statement.
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Decl is a top-level declaration (or layout element) of a File.
+type Decl interface {
+	Node
+	declNode()
+}
+
+// Qual is a set of cv-qualifiers.
+type Qual uint8
+
+const (
+	Const Qual = 1 << iota
+	Volatile
+)
+
+// String renders the qualifiers in "const volatile " order with a trailing
+// space, or the empty string when q is empty.
+func (q Qual) String() string {
+	s := ""
+	if q&Const != 0 {
+		s += "const "
+	}
+	if q&Volatile != 0 {
+		s += "volatile "
+	}
+	return s
+}
+
+// ---- Types ----
+
+// Named is a type referred to by name: a scalar typedef, "struct S0", "void", ...
+type Named struct {
+	Name string
+	Qual Qual
+}
+
+// Pointer is a pointer to Elem; Qual qualifies the pointer object itself.
+type Pointer struct {
+	Elem Type
+	Qual Qual
+}
+
+// Array is an array of Len elements; a negative Len prints as "[]".
+type Array struct {
+	Elem Type
+	Len  int
+}
+
+// ---- Expressions ----
+
+// Ident is a reference to a named object or function.
+type Ident struct {
+	Name string
+}
+
+// Lit is a literal printed verbatim (numbers with suffixes, strings).
+type Lit struct {
+	Text string
+}
+
+// Paren wraps X in parentheses.
+type Paren struct {
+	X Expr
+}
+
+// Cast prints as "(Type)X"; X is not parenthesized implicitly.
+type Cast struct {
+	Type Type
+	X    Expr
+}
+
+// Temporary is a C++ value-initialized temporary of Type, printed as "T()",
+// or as "T{}" when Braced is set.
+type Temporary struct {
+	Type   Type
+	Braced bool
+}
+
+// Unary is a prefix operator: "-", "~", "!", "*", "&", "++", "--", "+".
+type Unary struct {
+	Op string
+	X  Expr
+}
+
+// Postfix is a postfix "++" or "--".
+type Postfix struct {
+	X  Expr
+	Op string
+}
+
+// Binary is "X Op Y" without implicit parentheses.
+type Binary struct {
+	X  Expr
+	Op string
+	Y  Expr
+}
+
+// Assign is "LHS Op RHS" where Op is "=" or a compound assignment operator.
+type Assign struct {
+	LHS Expr
+	Op  string
+	RHS Expr
+}
+
+// Comma is "X, Y".
+type Comma struct {
+	X Expr
+	Y Expr
+}
+
+// Call is a function call.
+type Call struct {
+	Fun  Expr
+	Args []Expr
+}
+
+// Index is "X[Index]".
+type Index struct {
+	X     Expr
+	Index Expr
+}
+
+// Member is "X.Name", or "X->Name" when Arrow is set.
+type Member struct {
+	X     Expr
+	Name  string
+	Arrow bool
+}
+
+// InitList is a brace-enclosed initializer "{a,b,...}".
+type InitList struct {
+	Elems []Expr
+}
+
+// ---- Statements ----
+
+// ExprStmt is an expression evaluated for its side effects.
+type ExprStmt struct {
+	X Expr
+}
+
+// Block is a brace-enclosed statement list.
+type Block struct {
+	Stmts []Stmt
+}
+
+// If is an if statement; Else may be nil. A Then that is not a *Block is
+// printed on the same line as the condition.
+type If struct {
+	Cond Expr
+	Then Stmt
+	Else Stmt
+}
+
+// For is a for loop. Init is an *ExprStmt or *VarDecl printed without its
+// semicolon; Prologue, when set, is printed one level deeper than the body
+// as the first statement of the loop.
+type For struct {
+	Init     Stmt
+	Cond     Expr
+	Post     Expr
+	Prologue Stmt
+	Body     Stmt
+}
+
+// Return is a return statement; X may be nil.
+type Return struct {
+	X Expr
+}
+
+// Continue is a continue statement.
+type Continue struct{}
+
+// Break is a break statement.
+type Break struct{}
+
+// Goto jumps to Label.
+type Goto struct {
+	Label string
+}
+
+// Labeled is "Label: Stmt". Attrs are GCC label attributes; a label carrying
+// them is printed as "Label: __attribute__((...));" ahead of Stmt, since C++
+// only accepts label attributes before a null statement.
+type Labeled struct {
+	Label string
+	Attrs []string
+	Stmt  Stmt
+}
+
+// ---- Declarations ----
+
+// File is a translation unit.
+type File struct {
+	Decls []Decl
+}
+
+// Comment is printed verbatim (it carries its own comment delimiters). It may
+// appear both at file scope and as a statement.
+type Comment struct {
+	Text string
+}
+
+// Include is a quoted #include directive.
+type Include struct {
+	Path string
+}
+
+// Directive is a preprocessor line other than #include; Text follows the '#'.
+type Directive struct {
+	Text string
+}
+
+// Verbatim is source text, possibly spanning several lines, copied into the
+// output unchanged.
+type Verbatim struct {
+	Text string
+}
+
+// Blank is an empty line.
+type Blank struct{}
+
+// VarDecl declares an object. It is usable both at file scope and as a
+// statement. Storage is the storage-class keyword ("static", "extern") or empty.
+// Attrs are GCC attributes, printed after the declarator.
+type VarDecl struct {
+	Storage string
+	Type    Type
+	Name    string
+	Attrs   []string
+	Init    Expr
+}
+
+// Field is a struct or union member; BitWidth < 0 means it is not a bit-field.
+type Field struct {
+	Type     Type
+	Name     string
+	BitWidth int
+}
+
+// StructDecl defines a struct or union type. Kind is "struct" or "union".
+// Attrs are GCC type attributes, printed after the closing brace.
+type StructDecl struct {
+	Kind   string
+	Name   string
+	Attrs  []string
+	Fields []*Field
+}
+
+// Param is a function parameter.
+type Param struct {
+	Type Type
+	Name string
+}
+
+// FuncDecl is a function prototype. Attrs are GCC attributes, printed as
+// __attribute__((...)) ahead of the return type.
+type FuncDecl struct {
+	Storage string
+	Inline  bool
+	Attrs   []string
+	Ret     Type
+	Name    string
+	Params  []*Param
+}
+
+// FuncDef is a function definition. The opening brace follows the header
+// unless BraceOnOwnLine is set.
+type FuncDef struct {
+	FuncDecl
+	Body           *Block
+	BraceOnOwnLine bool
+}
+
+func (*Named) node()      {}
+func (*Pointer) node()    {}
+func (*Array) node()      {}
+func (*Ident) node()      {}
+func (*Lit) node()        {}
+func (*Paren) node()      {}
+func (*Cast) node()       {}
+func (*Temporary) node()  {}
+func (*Unary) node()      {}
+func (*Postfix) node()    {}
+func (*Binary) node()     {}
+func (*Assign) node()     {}
+func (*Comma) node()      {}
+func (*Call) node()       {}
+func (*Index) node()      {}
+func (*Member) node()     {}
+func (*InitList) node()   {}
+func (*ExprStmt) node()   {}
+func (*Block) node()      {}
+func (*If) node()         {}
+func (*For) node()        {}
+func (*Return) node()     {}
+func (*Continue) node()   {}
+func (*Break) node()      {}
+func (*Goto) node()       {}
+func (*Labeled) node()    {}
+func (*File) node()       {}
+func (*Comment) node()    {}
+func (*Include) node()    {}
+func (*Directive) node()  {}
+func (*Verbatim) node()   {}
+func (*Blank) node()      {}
+func (*VarDecl) node()    {}
+func (*Field) node()      {}
+func (*StructDecl) node() {}
+func (*Param) node()      {}
+func (*FuncDecl) node()   {}
+func (*FuncDef) node()    {}
+
+func (*Named) typeNode()   {}
+func (*Pointer) typeNode() {}
+func (*Array) typeNode()   {}
+
+func (*Ident) exprNode()     {}
+func (*Lit) exprNode()       {}
+func (*Paren) exprNode()     {}
+func (*Cast) exprNode()      {}
+func (*Temporary) exprNode() {}
+func (*Unary) exprNode()     {}
+func (*Postfix) exprNode()   {}
+func (*Binary) exprNode()    {}
+func (*Assign) exprNode()    {}
+func (*Comma) exprNode()     {}
+func (*Call) exprNode()      {}
+func (*Index) exprNode()     {}
+func (*Member) exprNode()    {}
+func (*InitList) exprNode()  {}
+
+func (*ExprStmt) stmtNode() {}
+func (*Block) stmtNode()    {}
+func (*If) stmtNode()       {}
+func (*For) stmtNode()      {}
+func (*Return) stmtNode()   {}
+func (*Continue) stmtNode() {}
+func (*Break) stmtNode()    {}
+func (*Goto) stmtNode()     {}
+func (*Labeled) stmtNode()  {}
+func (*VarDecl) stmtNode()  {}
+func (*Comment) stmtNode()  {}
+
+func (*Comment) declNode()    {}
+func (*Include) declNode()    {}
+func (*Directive) declNode()  {}
+func (*Verbatim) declNode()   {}
+func (*Blank) declNode()      {}
+func (*VarDecl) declNode()    {}
+func (*StructDecl) declNode() {}
+func (*FuncDecl) declNode()   {}
+func (*FuncDef) declNode()    {}
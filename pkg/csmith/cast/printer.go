@@ -0,0 +1,328 @@
+package cast
+
+import (
+	"fmt"
+	"strings"
+)
+
+const indentUnit = "    "
+
+// Print renders n as C source text.
+//
+// Bodies of braced if/for statements are printed at the indentation of the
+// statement that owns them, which is the layout the generator has always
+// emitted; unbraced bodies and For prologues are indented one level deeper.
+func Print(n Node) string {
+	var p printer
+	p.node(n, 0)
+	return p.b.String()
+}
+
+type printer struct {
+	b strings.Builder
+}
+
+func (p *printer) line(indent int, s string) {
+	for i := 0; i < indent; i++ {
+		p.b.WriteString(indentUnit)
+	}
+	p.b.WriteString(s)
+	p.b.WriteByte('\n')
+}
+
+func (p *printer) node(n Node, indent int) {
+	switch n := n.(type) {
+	case *File:
+		for _, d := range n.Decls {
+			p.decl(d)
+		}
+	case Decl:
+		p.decl(n)
+	case Stmt:
+		p.stmt(n, indent)
+	case Expr:
+		p.b.WriteString(ExprString(n))
+	case Type:
+		p.b.WriteString(TypeName(n))
+	default:
+		panic(fmt.Sprintf("cast: cannot print %T", n))
+	}
+}
+
+func (p *printer) decl(d Decl) {
+	switch d := d.(type) {
+	case *Comment:
+		p.line(0, d.Text)
+	case *Include:
+		p.line(0, fmt.Sprintf("#include \"%s\"", d.Path))
+	case *Directive:
+		p.line(0, "#"+d.Text)
+	case *Verbatim:
+		p.b.WriteString(d.Text)
+	case *Blank:
+		p.line(0, "")
+	case *VarDecl:
+		p.line(0, varDecl(d)+";")
+	case *StructDecl:
+		p.line(0, fmt.Sprintf("%s %s {", d.Kind, d.Name))
+		for _, f := range d.Fields {
+			p.line(1, field(f))
+		}
+		if len(d.Attrs) > 0 {
+			p.line(0, "} "+attributes(d.Attrs)+";")
+		} else {
+			p.line(0, "};")
+		}
+	case *FuncDecl:
+		p.line(0, funcHeader(d)+";")
+	case *FuncDef:
+		if d.BraceOnOwnLine {
+			p.line(0, funcHeader(&d.FuncDecl))
+			p.line(0, "{")
+		} else {
+			p.line(0, funcHeader(&d.FuncDecl)+" {")
+		}
+		p.stmts(d.Body.Stmts, 1)
+		p.line(0, "}")
+	default:
+		panic(fmt.Sprintf("cast: unexpected declaration %T", d))
+	}
+}
+
+func (p *printer) stmts(list []Stmt, indent int) {
+	for _, s := range list {
+		p.stmt(s, indent)
+	}
+}
+
+func (p *printer) stmt(s Stmt, indent int) {
+	switch s := s.(type) {
+	case *Block:
+		p.line(indent, "{")
+		p.stmts(s.Stmts, indent)
+		p.line(indent, "}")
+	case *If:
+		head := fmt.Sprintf("if (%s)", ExprString(s.Cond))
+		then, ok := s.Then.(*Block)
+		if !ok {
+			p.line(indent, head+" "+simpleStmt(s.Then)+";")
+			return
+		}
+		p.line(indent, head+" {")
+		p.stmts(then.Stmts, indent)
+		if s.Else != nil {
+			p.line(indent, "} else {")
+			if els, ok := s.Else.(*Block); ok {
+				p.stmts(els.Stmts, indent)
+			} else {
+				p.stmt(s.Else, indent)
+			}
+		}
+		p.line(indent, "}")
+	case *For:
+		init := ""
+		if s.Init != nil {
+			init = simpleStmt(s.Init)
+		}
+		head := fmt.Sprintf("for (%s; %s; %s)", init, exprOrEmpty(s.Cond), exprOrEmpty(s.Post))
+		body, ok := s.Body.(*Block)
+		if !ok {
+			p.line(indent, head)
+			p.stmt(s.Body, indent+1)
+			return
+		}
+		p.line(indent, head+" {")
+		if s.Prologue != nil {
+			p.stmt(s.Prologue, indent+1)
+		}
+		p.stmts(body.Stmts, indent)
+		p.line(indent, "}")
+	case *Comment:
+		p.line(indent, s.Text)
+	case *Labeled:
+		if len(s.Attrs) > 0 {
+			p.line(indent, s.Label+": "+attributes(s.Attrs)+";")
+		} else {
+			p.line(indent, s.Label+":")
+		}
+		p.stmt(s.Stmt, indent)
+	default:
+		p.line(indent, simpleStmt(s)+";")
+	}
+}
+
+// simpleStmt renders a statement that fits on one line, without the
+// terminating semicolon.
+func simpleStmt(s Stmt) string {
+	switch s := s.(type) {
+	case *ExprStmt:
+		return ExprString(s.X)
+	case *VarDecl:
+		return varDecl(s)
+	case *Return:
+		if s.X == nil {
+			return "return"
+		}
+		return "return " + ExprString(s.X)
+	case *Continue:
+		return "continue"
+	case *Break:
+		return "break"
+	case *Goto:
+		return "goto " + s.Label
+	default:
+		panic(fmt.Sprintf("cast: %T is not a simple statement", s))
+	}
+}
+
+func exprOrEmpty(e Expr) string {
+	if e == nil {
+		return ""
+	}
+	return ExprString(e)
+}
+
+// ExprString renders an expression.
+func ExprString(e Expr) string {
+	switch e := e.(type) {
+	case *Ident:
+		return e.Name
+	case *Lit:
+		return e.Text
+	case *Paren:
+		return "(" + ExprString(e.X) + ")"
+	case *Cast:
+		return "(" + TypeName(e.Type) + ")" + ExprString(e.X)
+	case *Temporary:
+		if e.Braced {
+			return TypeName(e.Type) + "{}"
+		}
+		return TypeName(e.Type) + "()"
+	case *Unary:
+		return e.Op + ExprString(e.X)
+	case *Postfix:
+		return ExprString(e.X) + e.Op
+	case *Binary:
+		return ExprString(e.X) + " " + e.Op + " " + ExprString(e.Y)
+	case *Assign:
+		return ExprString(e.LHS) + " " + e.Op + " " + ExprString(e.RHS)
+	case *Comma:
+		return ExprString(e.X) + ", " + ExprString(e.Y)
+	case *Call:
+		args := make([]string, 0, len(e.Args))
+		for _, a := range e.Args {
+			args = append(args, ExprString(a))
+		}
+		return ExprString(e.Fun) + "(" + strings.Join(args, ", ") + ")"
+	case *Index:
+		return ExprString(e.X) + "[" + ExprString(e.Index) + "]"
+	case *Member:
+		if e.Arrow {
+			return ExprString(e.X) + "->" + e.Name
+		}
+		return ExprString(e.X) + "." + e.Name
+	case *InitList:
+		elems := make([]string, 0, len(e.Elems))
+		for _, x := range e.Elems {
+			elems = append(elems, ExprString(x))
+		}
+		return "{" + strings.Join(elems, ",") + "}"
+	default:
+		panic(fmt.Sprintf("cast: unexpected expression %T", e))
+	}
+}
+
+// TypeName renders t as an abstract declarator, as used in casts.
+func TypeName(t Type) string {
+	return typeSpec(t) + declarator(t, "")
+}
+
+// typeSpec returns the qualified base type of t.
+func typeSpec(t Type) string {
+	switch t := t.(type) {
+	case *Named:
+		return t.Qual.String() + t.Name
+	case *Pointer:
+		return typeSpec(t.Elem)
+	case *Array:
+		return typeSpec(t.Elem)
+	default:
+		panic(fmt.Sprintf("cast: unexpected type %T", t))
+	}
+}
+
+// declarator wraps inner (a name, or a partially built declarator) with the
+// pointer and array derivations of t.
+func declarator(t Type, inner string) string {
+	switch t := t.(type) {
+	case *Named:
+		return inner
+	case *Pointer:
+		inner = "*" + t.Qual.String() + inner
+		if _, ok := t.Elem.(*Array); ok {
+			inner = "(" + inner + ")"
+		}
+		return declarator(t.Elem, inner)
+	case *Array:
+		if t.Len < 0 {
+			return declarator(t.Elem, inner+"[]")
+		}
+		return declarator(t.Elem, fmt.Sprintf("%s[%d]", inner, t.Len))
+	default:
+		panic(fmt.Sprintf("cast: unexpected type %T", t))
+	}
+}
+
+// Declaration renders "T name" for t, e.g. "int32_t *p" or "char *argv[]".
+func Declaration(t Type, name string) string {
+	return typeSpec(t) + " " + declarator(t, name)
+}
+
+func varDecl(d *VarDecl) string {
+	s := Declaration(d.Type, d.Name)
+	if d.Storage != "" {
+		s = d.Storage + " " + s
+	}
+	if len(d.Attrs) > 0 {
+		s += " " + attributes(d.Attrs)
+	}
+	if d.Init != nil {
+		s += " = " + ExprString(d.Init)
+	}
+	return s
+}
+
+func field(f *Field) string {
+	s := Declaration(f.Type, f.Name)
+	if f.BitWidth >= 0 {
+		s += fmt.Sprintf(" : %d", f.BitWidth)
+	}
+	return s + ";"
+}
+
+func funcHeader(d *FuncDecl) string {
+	params := "void"
+	if len(d.Params) > 0 {
+		pp := make([]string, 0, len(d.Params))
+		for _, prm := range d.Params {
+			pp = append(pp, Declaration(prm.Type, prm.Name))
+		}
+		params = strings.Join(pp, ", ")
+	}
+	s := typeSpec(d.Ret) + " " + declarator(d.Ret, fmt.Sprintf("%s(%s)", d.Name, params))
+	if len(d.Attrs) > 0 {
+		s = attributes(d.Attrs) + " " + s
+	}
+	if d.Inline {
+		s = "inline " + s
+	}
+	if d.Storage != "" {
+		s = d.Storage + " " + s
+	}
+	return s
+}
+
+// attributes renders a GCC attribute specifier.
+func attributes(attrs []string) string {
+	return "__attribute__((" + strings.Join(attrs, ", ") + "))"
+}
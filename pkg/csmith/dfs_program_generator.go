@@ -0,0 +1,250 @@
+package csmith
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// dfsChoice is one recorded decision: the value taken out of n alternatives.
+type dfsChoice struct {
+	val uint32
+	n   uint32
+}
+
+// dfsSequence replaces rnd_upto/rnd_flipcoin with a walk over the decision tree,
+// mirroring upstream DFSRndNumGenerator. The first maxDepth decisions follow the
+// recorded path; decisions past that depth come from a seeded tail RNG so every
+// path still produces a complete program.
+type dfsSequence struct {
+	choices   []dfsChoice
+	pos       int
+	maxDepth  int
+	seed      uint64
+	tail      *rng
+	dead      bool
+	suspended int
+}
+
+func newDFSSequence(seed uint64, maxDepth int, prefix []uint32) *dfsSequence {
+	s := &dfsSequence{maxDepth: maxDepth, seed: seed}
+	for _, v := range prefix {
+		s.choices = append(s.choices, dfsChoice{val: v})
+	}
+	if len(prefix) > s.maxDepth {
+		s.maxDepth = len(prefix)
+	}
+	s.rewind()
+	return s
+}
+
+func (s *dfsSequence) rewind() {
+	s.pos = 0
+	s.dead = false
+	s.suspended = 0
+	s.tail = newRNG(s.seed)
+}
+
+func (s *dfsSequence) upto(n uint32, reject func(uint32) bool) uint32 {
+	if s.suspended > 0 || s.pos >= s.maxDepth {
+		return s.tail.uptoWithFilter(n, reject)
+	}
+	v := uint32(0)
+	if s.pos < len(s.choices) {
+		v = s.choices[s.pos].val
+	}
+	for v < n && reject != nil && reject(v) {
+		v++
+	}
+	if v >= n {
+		// The recorded branch is not available on this path (e.g. a replayed
+		// --dfs-debug-sequence entry out of range); the program is discarded.
+		s.dead = true
+		return s.tail.uptoWithFilter(n, reject)
+	}
+	if s.pos < len(s.choices) {
+		s.choices[s.pos] = dfsChoice{val: v, n: n}
+	} else {
+		s.choices = append(s.choices, dfsChoice{val: v, n: n})
+	}
+	s.pos++
+	return v
+}
+
+func (s *dfsSequence) flipcoin(p uint32) bool {
+	if p == 0 {
+		return false
+	}
+	if p >= 100 {
+		return true
+	}
+	return s.upto(2, nil) == 1
+}
+
+// suspend routes decisions to the tail RNG until the matching resume,
+// used by --partial-expand for statements that are not expanded exhaustively.
+func (s *dfsSequence) suspend() {
+	s.suspended++
+}
+
+func (s *dfsSequence) resume() {
+	if s.suspended > 0 {
+		s.suspended--
+	}
+}
+
+// advance moves to the next unexplored path. It reports false once the whole
+// tree up to maxDepth has been enumerated.
+func (s *dfsSequence) advance() bool {
+	s.choices = s.choices[:s.pos]
+	for i := len(s.choices) - 1; i >= 0; i-- {
+		if s.choices[i].val+1 < s.choices[i].n {
+			s.choices[i].val++
+			s.choices = s.choices[:i+1]
+			s.rewind()
+			return true
+		}
+	}
+	return false
+}
+
+func (s *dfsSequence) name() string {
+	if s.pos == 0 {
+		return "0"
+	}
+	parts := make([]string, 0, s.pos)
+	for _, c := range s.choices[:s.pos] {
+		parts = append(parts, strconv.FormatUint(uint64(c.val), 10))
+	}
+	return strings.Join(parts, "_")
+}
+
+func parseDFSSequence(seq string) ([]uint32, error) {
+	seq = strings.TrimSpace(seq)
+	if seq == "" {
+		return nil, nil
+	}
+	parts := strings.Split(seq, "_")
+	out := make([]uint32, 0, len(parts))
+	for _, p := range parts {
+		v, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dfs-debug-sequence %q", seq)
+		}
+		out = append(out, uint32(v))
+	}
+	return out, nil
+}
+
+// dfsPartialExpandKinds maps --partial-expand names to statement kinds.
+var dfsPartialExpandKinds = map[string]stmtKind{
+	"assignment": stmtAssign,
+	"if-else":    stmtIfElse,
+	"for":        stmtFor,
+	"return":     stmtReturn,
+	"continue":   stmtContinue,
+	"break":      stmtBreak,
+	"goto":       stmtGoto,
+	"arrayop":    stmtArrayOp,
+}
+
+func parsePartialExpand(s string) (map[stmtKind]bool, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	out := make(map[stmtKind]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		k, ok := dfsPartialExpandKinds[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid partial-expand statement kind %q", name)
+		}
+		out[k] = true
+	}
+	return out, nil
+}
+
+// partialExpandAllows reports whether statements of kind k are expanded
+// exhaustively. An empty --partial-expand expands everything.
+func (o Options) partialExpandAllows(k stmtKind) bool {
+	return o.partialExpand == nil || o.partialExpand[k]
+}
+
+// dfsProgramGenerator mirrors upstream DFSProgramGenerator: it re-runs the
+// default generation flow once per decision-tree path and emits every program.
+type dfsProgramGenerator struct {
+	opts   Options
+	seq    *dfsSequence
+	single bool
+	extra  []outputFile
+	err    error
+}
+
+func newDFSProgramGenerator(opts Options) *dfsProgramGenerator {
+	return &dfsProgramGenerator{opts: opts}
+}
+
+func (g *dfsProgramGenerator) initialize() {
+	// Options are validated before the generator is created, so the sequence parses.
+	prefix, _ := parseDFSSequence(g.opts.DFSDebugSequence)
+	g.single = len(prefix) > 0
+	g.seq = newDFSSequence(g.opts.Seed, g.opts.MaxExhaustiveDepth, prefix)
+}
+
+func (g *dfsProgramGenerator) goGenerator() string {
+	var b strings.Builder
+	first := true
+	for {
+		gen := newDefaultProgramGenerator(g.opts)
+		gen.initialize()
+		gen.r.dfs = g.seq
+		if first {
+			// The --dump-random-probabilities table is drawn before the
+			// sequence takes over, so every program shares it.
+			g.extra = append(g.extra, gen.extra...)
+			first = false
+		}
+		program := gen.goGenerator()
+		if !g.seq.dead {
+			name := g.seq.name()
+			fmt.Fprintf(&b, "/* --- DFS SEQUENCE %s --- */\n", name)
+			b.WriteString(program)
+			if g.opts.SequenceNamePrefix {
+				g.writeSequenceFile(name, program)
+			}
+		}
+		if g.single {
+			if g.seq.dead {
+				g.err = fmt.Errorf("dfs-debug-sequence %q does not lead to a program", g.opts.DFSDebugSequence)
+			}
+			break
+		}
+		if !g.seq.advance() {
+			break
+		}
+	}
+	return b.String()
+}
+
+func (g *dfsProgramGenerator) extraOutputs() []outputFile {
+	return g.extra
+}
+
+func (g *dfsProgramGenerator) generationError() error {
+	return g.err
+}
+
+// writeSequenceFile stores one enumerated program as <sequence>_<output name>,
+// next to the requested output file (or program.c in the working directory).
+func (g *dfsProgramGenerator) writeSequenceFile(name string, program string) {
+	dir, base := ".", "program.c"
+	if g.opts.OutputPath != "" {
+		dir, base = filepath.Split(g.opts.OutputPath)
+		if dir == "" {
+			dir = "."
+		}
+	}
+	g.extra = append(g.extra, outputFile{path: filepath.Join(dir, name+"_"+base), content: program})
+}
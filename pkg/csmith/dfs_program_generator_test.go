@@ -0,0 +1,66 @@
+package csmith
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDFSSequence(t *testing.T) {
+	got, err := parseDFSSequence("1_0_12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint32{1, 0, 12}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("parseDFSSequence(1_0_12) = %v, want %v", got, want)
+	}
+	if got, err := parseDFSSequence(""); err != nil || got != nil {
+		t.Fatalf("parseDFSSequence(\"\") = %v, %v; want nil, nil", got, err)
+	}
+	for _, bad := range []string{"1__2", "a", "1_-1"} {
+		if _, err := parseDFSSequence(bad); err == nil {
+			t.Errorf("parseDFSSequence(%q) succeeded", bad)
+		}
+	}
+}
+
+// TestDFSSequenceEnumeratesTree walks a tree of a 3-way then a 2-way decision
+// and expects every path exactly once.
+func TestDFSSequenceEnumeratesTree(t *testing.T) {
+	s := newDFSSequence(1, 2, nil)
+	seen := map[string]bool{}
+	for {
+		s.upto(3, nil)
+		s.upto(2, nil)
+		name := s.name()
+		if seen[name] {
+			t.Fatalf("path %s enumerated twice", name)
+		}
+		seen[name] = true
+		if !s.advance() {
+			break
+		}
+	}
+	if len(seen) != 6 {
+		t.Fatalf("enumerated %d paths, want 6: %v", len(seen), seen)
+	}
+}
+
+func TestDFSDebugSequenceOutOfRange(t *testing.T) {
+	opts := Defaults()
+	opts.DFSExhaustive = true
+	opts.RandomBased = false
+	opts.MaxExhaustiveDepth = 5
+	opts.DFSDebugSequence = "99_99_99"
+	_, err := Generate(opts)
+	if err == nil || !strings.Contains(err.Error(), "does not lead to a program") {
+		t.Fatalf("Generate = %v, want an error for the unreachable sequence", err)
+	}
+	opts.DFSDebugSequence = "0_1"
+	program, err := Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(program, "/* --- DFS SEQUENCE 0_1") {
+		t.Fatalf("program does not start with the replayed sequence:\n%.80s", program)
+	}
+}
@@ -0,0 +1,367 @@
+package csmith
+
+import (
+	"strings"
+
+	"csmith/pkg/csmith/cast"
+)
+
+// extension mirrors upstream AbsExtension: a harness flavour (--klee, --crest,
+// --coverage-test) that turns the inputs of func_1 into values supplied by main, and takes over
+// the part of main that feeds and checks them.
+type extension interface {
+	// includes lists the headers the harness needs besides the runtime.
+	includes() []string
+	// generateValues picks the harness inputs once the program, f, is generated.
+	generateValues(r *rng, entry funcInfo, env envInfo, f *cast.File)
+	// outputInit declares and initializes the arguments of func_1.
+	outputInit(body *cast.Block)
+	// outputInvocation calls func_1 and checks the resulting checksum.
+	outputInvocation(body *cast.Block, entry funcInfo, opts Options)
+}
+
+func newExtension(opts Options) extension {
+	switch {
+	case opts.Klee:
+		return &kleeExtension{opts: opts}
+	case opts.Crest:
+		return &crestExtension{}
+	case opts.CoverageTest:
+		return &coverageTestExtension{opts: opts}
+	}
+	return nil
+}
+
+func (o Options) hasExtension() bool {
+	return o.Klee || o.Crest || o.CoverageTest
+}
+
+// extensionParams draws the Func1MaxParams parameters func_1 takes under an
+// extension, all of simple type. CREST only has input macros for integers of
+// up to 32 bits.
+func (s *functionFlowState) extensionParams(r *rng) []paramInfo {
+	disabled := disabledTypeFilter(s.pool, s.opts)
+	reject := func(i uint32) bool {
+		if disabled(i) {
+			return true
+		}
+		_, ok := crestInputMacro(s.pool[i])
+		return s.opts.Crest && !ok
+	}
+	n := max(1, s.opts.Func1MaxParams)
+	params := make([]paramInfo, 0, n)
+	for i := 0; i < n; i++ {
+		t := s.pool[int(r.uptoWithFilter(uint32(len(s.pool)), reject))]
+		params = append(params, paramInfo{name: s.allocParamName(), ctype: t})
+	}
+	return params
+}
+
+// declareArgs declares one local per parameter of entry, named after it.
+func declareArgs(body *cast.Block, entry funcInfo) {
+	for _, p := range entry.params {
+		addStmt(body, &cast.VarDecl{Type: typeNode(p.ctype), Name: p.name})
+	}
+}
+
+func entryCall(entry funcInfo) cast.Expr {
+	args := make([]cast.Expr, 0, len(entry.params))
+	for _, p := range entry.params {
+		args = append(args, ident(p.name))
+	}
+	return &cast.Cast{Type: &cast.Named{Name: "void"}, X: call(entry.name, args...)}
+}
+
+// kleeExtension makes the arguments of func_1 and a random subset of the
+// writable globals without pointers symbolic. Instead of printing the checksum,
+// main runs func_1 twice from the same symbolic state, restoring the globals in
+// between, and asserts that both runs leave the same checksum: on every path
+// KLEE explores, the program must behave deterministically.
+type kleeExtension struct {
+	opts     Options
+	entry    funcInfo
+	symbolic []string
+	globals  []*cast.VarDecl
+}
+
+func (k *kleeExtension) includes() []string {
+	return []string{"klee/klee.h"}
+}
+
+func (k *kleeExtension) generateValues(r *rng, entry funcInfo, env envInfo, f *cast.File) {
+	k.entry = entry
+	k.globals = writableGlobals(f)
+	aggregates := aggregateDecls(f)
+	for _, g := range k.globals {
+		// klee_make_symbolic takes a plain void *, and a symbolic pointer would
+		// send every dereference out of bounds.
+		if volatileType(g.Type) || pointerType(g.Type, aggregates) {
+			continue
+		}
+		if r.flipcoin(50) {
+			k.symbolic = append(k.symbolic, g.Name)
+		}
+	}
+}
+
+func (k *kleeExtension) outputInit(body *cast.Block) {
+	declareArgs(body, k.entry)
+	names := make([]string, 0, len(k.entry.params)+len(k.symbolic))
+	for _, p := range k.entry.params {
+		names = append(names, p.name)
+	}
+	names = append(names, k.symbolic...)
+	for _, name := range names {
+		addStmt(body, exprStmt(call("klee_make_symbolic", addrOf(ident(name)), call("sizeof", ident(name)), lit("%q", name))))
+	}
+	if !k.opts.ComputeHash {
+		return
+	}
+	for _, g := range k.globals {
+		addStmt(body, &cast.VarDecl{Storage: "static", Type: g.Type, Name: g.Name + "_init"})
+	}
+	for _, g := range k.globals {
+		addStmt(body, copyBytes(objectBytes(g.Name+"_init"), objectBytes(g.Name), g.Name))
+	}
+}
+
+func (k *kleeExtension) outputInvocation(body *cast.Block, entry funcInfo, opts Options) {
+	addStmt(body, exprStmt(entryCall(entry)))
+	if opts.ComputeHash {
+		checksum := binary(ident("crc32_context"), "^", lit("0xFFFFFFFFUL"))
+		addStmt(body,
+			exprStmt(call("csmith_compute_hash", lit("0"))),
+			&cast.VarDecl{Type: &cast.Named{Name: "uint32_t"}, Name: "checksum", Init: checksum},
+			exprStmt(assign(ident("crc32_context"), "=", lit("0xFFFFFFFFUL"))),
+		)
+		for _, g := range k.globals {
+			addStmt(body, copyBytes(objectBytes(g.Name), objectBytes(g.Name+"_init"), g.Name))
+		}
+		addStmt(body,
+			exprStmt(entryCall(entry)),
+			exprStmt(call("csmith_compute_hash", lit("0"))),
+			exprStmt(call("klee_assert", binary(paren(checksum), "==", ident("checksum")))),
+		)
+	}
+	if opts.identifiesWrappers() {
+		addStmt(body, exprStmt(call("safe_math_report")))
+	}
+}
+
+// crestExtension reads the arguments of func_1 as CREST inputs. CREST runs the
+// program once per explored path, so main stays a single straight-line
+// invocation whose checksum is printed as usual.
+type crestExtension struct {
+	entry funcInfo
+}
+
+func (c *crestExtension) includes() []string {
+	return []string{"crest.h"}
+}
+
+func (c *crestExtension) generateValues(r *rng, entry funcInfo, env envInfo, f *cast.File) {
+	c.entry = entry
+}
+
+func (c *crestExtension) outputInit(body *cast.Block) {
+	declareArgs(body, c.entry)
+	for _, p := range c.entry.params {
+		macro, _ := crestInputMacro(p.ctype)
+		addStmt(body, exprStmt(call(macro, ident(p.name))))
+	}
+}
+
+func (c *crestExtension) outputInvocation(body *cast.Block, entry funcInfo, opts Options) {
+	emitEntryInvocation(body, entry, opts)
+}
+
+// crestInputMacro returns the CREST macro declaring an input of type t.
+func crestInputMacro(t CType) (string, bool) {
+	switch t.Name {
+	case "int8_t":
+		return "CREST_char", true
+	case "uint8_t":
+		return "CREST_unsigned_char", true
+	case "int16_t":
+		return "CREST_short", true
+	case "uint16_t":
+		return "CREST_unsigned_short", true
+	case "int32_t":
+		return "CREST_int", true
+	case "uint32_t":
+		return "CREST_unsigned_int", true
+	}
+	return "", false
+}
+
+// coverageTestExtension runs func_1 over CoverageTestSize input vectors drawn
+// at generation time, hashing the globals and printing the checksum after
+// every run. Each run starts from the initial globals: main copies every
+// writable global to a snapshot of the same type before the first run and
+// copies it back before each one.
+type coverageTestExtension struct {
+	opts    Options
+	entry   funcInfo
+	values  [][]cast.Expr
+	globals []*cast.VarDecl
+}
+
+func (c *coverageTestExtension) includes() []string {
+	return nil
+}
+
+// generateValues draws the input vectors, one column per parameter.
+func (c *coverageTestExtension) generateValues(r *rng, entry funcInfo, env envInfo, f *cast.File) {
+	c.entry = entry
+	c.globals = writableGlobals(f)
+	c.values = make([][]cast.Expr, len(entry.params))
+	for i, p := range entry.params {
+		for n := 0; n < c.opts.CoverageTestSize; n++ {
+			c.values[i] = append(c.values[i], randomConstantExpr(p.ctype, r, c.opts))
+		}
+	}
+}
+
+func (c *coverageTestExtension) outputInit(body *cast.Block) {
+	for i, p := range c.entry.params {
+		addStmt(body, &cast.VarDecl{
+			Storage: "static",
+			Type:    &cast.Array{Elem: qualTypeNode(p.ctype, true, false), Len: c.opts.CoverageTestSize},
+			Name:    p.name,
+			Init:    &cast.InitList{Elems: c.values[i]},
+		})
+	}
+	for _, g := range c.globals {
+		addStmt(body, &cast.VarDecl{Storage: "static", Type: g.Type, Name: g.Name + "_init"})
+	}
+	for _, g := range c.globals {
+		addStmt(body, copyBytes(objectBytes(g.Name+"_init"), objectBytes(g.Name), g.Name))
+	}
+}
+
+// writableGlobals lists the declarations of the globals g_N of f that may be
+// overwritten as a whole: neither const nor holding a const member.
+func writableGlobals(f *cast.File) []*cast.VarDecl {
+	aggregates := aggregateDecls(f)
+	var out []*cast.VarDecl
+	for _, d := range f.Decls {
+		v, ok := d.(*cast.VarDecl)
+		if !ok || !strings.HasPrefix(v.Name, "g_") || constType(v.Type, aggregates) {
+			continue
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+// aggregateDecls maps the struct and union types declared in f to their
+// declarations.
+func aggregateDecls(f *cast.File) map[string]*cast.StructDecl {
+	out := make(map[string]*cast.StructDecl)
+	for _, d := range f.Decls {
+		if s, ok := d.(*cast.StructDecl); ok {
+			out[s.Kind+" "+s.Name] = s
+		}
+	}
+	return out
+}
+
+// constType reports whether an object of type t is const itself or, for a
+// struct or union declared in aggregates, has a const member at any depth.
+func constType(t cast.Type, aggregates map[string]*cast.StructDecl) bool {
+	switch t := t.(type) {
+	case *cast.Named:
+		if t.Qual&cast.Const != 0 {
+			return true
+		}
+		if s, ok := aggregates[t.Name]; ok {
+			for _, m := range s.Fields {
+				if constType(m.Type, aggregates) {
+					return true
+				}
+			}
+		}
+	case *cast.Pointer:
+		return t.Qual&cast.Const != 0
+	case *cast.Array:
+		return constType(t.Elem, aggregates)
+	}
+	return false
+}
+
+// pointerType reports whether an object of type t is or holds a pointer.
+func pointerType(t cast.Type, aggregates map[string]*cast.StructDecl) bool {
+	switch t := t.(type) {
+	case *cast.Named:
+		if s, ok := aggregates[t.Name]; ok {
+			for _, m := range s.Fields {
+				if pointerType(m.Type, aggregates) {
+					return true
+				}
+			}
+		}
+	case *cast.Pointer:
+		return true
+	case *cast.Array:
+		return pointerType(t.Elem, aggregates)
+	}
+	return false
+}
+
+// volatileType reports whether an object of type t is volatile itself.
+func volatileType(t cast.Type) bool {
+	switch t := t.(type) {
+	case *cast.Named:
+		return t.Qual&cast.Volatile != 0
+	case *cast.Pointer:
+		return t.Qual&cast.Volatile != 0
+	case *cast.Array:
+		return volatileType(t.Elem)
+	}
+	return false
+}
+
+// objectBytes views the object g as volatile bytes, which may be read and
+// written whatever g's type and qualifiers.
+func objectBytes(g string) cast.Expr {
+	bytes := &cast.Pointer{Elem: &cast.Named{Name: "unsigned char", Qual: cast.Volatile}}
+	return paren(&cast.Cast{Type: bytes, X: addrOf(ident(g))})
+}
+
+// copyBytes copies the sizeof(g) bytes of src to dst.
+func copyBytes(dst, src cast.Expr, g string) cast.Stmt {
+	k := ident("k")
+	return &cast.For{
+		Init: &cast.VarDecl{Type: &cast.Named{Name: "unsigned long"}, Name: "k", Init: lit("0")},
+		Cond: binary(k, "<", call("sizeof", ident(g))),
+		Post: &cast.Unary{Op: "++", X: k},
+		Body: &cast.Block{Stmts: []cast.Stmt{exprStmt(assign(index(dst, k), "=", index(src, k)))}},
+	}
+}
+
+func (c *coverageTestExtension) outputInvocation(body *cast.Block, entry funcInfo, opts Options) {
+	i := ident("i")
+	args := make([]cast.Expr, 0, len(entry.params))
+	for _, p := range entry.params {
+		args = append(args, index(ident(p.name), i))
+	}
+	run := &cast.Block{}
+	for _, g := range c.globals {
+		addStmt(run, copyBytes(objectBytes(g.Name), objectBytes(g.Name+"_init"), g.Name))
+	}
+	addStmt(run, exprStmt(&cast.Cast{Type: &cast.Named{Name: "void"}, X: call(entry.name, args...)}))
+	if opts.ComputeHash {
+		checksum := castExpr(CType{Name: "uint32_t", Bits: 32}, binary(ident("crc32_context"), "^", lit("0xFFFFFFFFUL")))
+		addStmt(run,
+			exprStmt(assign(ident("crc32_context"), "=", lit("0xFFFFFFFFUL"))),
+			exprStmt(call("csmith_compute_hash", ident("print_hash_value"))),
+			exprStmt(call("printf", lit(`"checksum[%%u] = %%X\n"`), i, checksum)),
+		)
+	}
+	addStmt(body, &cast.For{
+		Init: &cast.VarDecl{Type: &cast.Named{Name: "uint32_t"}, Name: "i", Init: lit("0")},
+		Cond: binary(i, "<", lit("%du", opts.CoverageTestSize)),
+		Post: &cast.Unary{Op: "++", X: i},
+		Body: run,
+	})
+}
@@ -0,0 +1,78 @@
+package csmith
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"csmith/pkg/csmith/cast"
+)
+
+func TestWritableGlobals(t *testing.T) {
+	u32 := func(q cast.Qual) cast.Type { return &cast.Named{Name: "uint32_t", Qual: q} }
+	f := &cast.File{Decls: []cast.Decl{
+		&cast.StructDecl{Kind: "struct", Name: "S0", Fields: []*cast.Field{{Type: u32(0), Name: "f0"}, {Type: u32(cast.Const), Name: "f1"}}},
+		&cast.StructDecl{Kind: "struct", Name: "S1", Fields: []*cast.Field{{Type: &cast.Named{Name: "struct S0"}, Name: "f0"}}},
+		&cast.StructDecl{Kind: "union", Name: "U0", Fields: []*cast.Field{{Type: u32(cast.Volatile), Name: "f0"}}},
+		&cast.VarDecl{Type: u32(0), Name: "g_0"},
+		&cast.VarDecl{Type: u32(cast.Const), Name: "g_1"},
+		&cast.VarDecl{Type: u32(cast.Volatile), Name: "g_2"},
+		&cast.VarDecl{Type: &cast.Pointer{Elem: u32(cast.Const)}, Name: "g_3"},
+		&cast.VarDecl{Type: &cast.Pointer{Elem: u32(0), Qual: cast.Const}, Name: "g_4"},
+		&cast.VarDecl{Type: &cast.Array{Elem: u32(cast.Const), Len: 2}, Name: "g_5"},
+		&cast.VarDecl{Type: &cast.Named{Name: "struct S0"}, Name: "g_6"},
+		&cast.VarDecl{Type: &cast.Array{Elem: &cast.Named{Name: "struct S1"}, Len: 2}, Name: "g_7"},
+		&cast.VarDecl{Type: &cast.Named{Name: "union U0"}, Name: "g_8"},
+		&cast.VarDecl{Type: &cast.Named{Name: "long"}, Name: "__undefined"},
+	}}
+	var got []string
+	for _, g := range writableGlobals(f) {
+		got = append(got, g.Name)
+	}
+	if want := []string{"g_0", "g_2", "g_3", "g_8"}; !slices.Equal(got, want) {
+		t.Fatalf("writableGlobals = %v, want %v", got, want)
+	}
+}
+
+func TestKleeSymbolicGlobals(t *testing.T) {
+	u32 := &cast.Named{Name: "uint32_t"}
+	f := &cast.File{Decls: []cast.Decl{
+		&cast.StructDecl{Kind: "struct", Name: "S0", Fields: []*cast.Field{{Type: &cast.Pointer{Elem: u32}, Name: "f0"}}},
+		&cast.StructDecl{Kind: "struct", Name: "S1", Fields: []*cast.Field{{Type: u32, Name: "f0"}}},
+		&cast.VarDecl{Type: u32, Name: "g_0"},
+		&cast.VarDecl{Type: &cast.Named{Name: "uint32_t", Qual: cast.Volatile}, Name: "g_1"},
+		&cast.VarDecl{Type: &cast.Pointer{Elem: u32}, Name: "g_2"},
+		&cast.VarDecl{Type: &cast.Array{Elem: &cast.Named{Name: "struct S0"}, Len: 2}, Name: "g_3"},
+		&cast.VarDecl{Type: &cast.Named{Name: "struct S1"}, Name: "g_4"},
+	}}
+	seen := map[string]bool{}
+	for seed := uint64(1); seed <= 50; seed++ {
+		k := &kleeExtension{opts: Defaults()}
+		k.generateValues(newRNG(seed), funcInfo{name: "func_1"}, envInfo{}, f)
+		for _, g := range k.symbolic {
+			seen[g] = true
+		}
+	}
+	if len(seen) != 2 || !seen["g_0"] || !seen["g_4"] {
+		t.Fatalf("symbolic globals %v, want g_0 and g_4", seen)
+	}
+}
+
+func TestKleeHarnessAsserts(t *testing.T) {
+	opts := Defaults()
+	opts.Klee = true
+	for seed := uint64(1); seed <= 10; seed++ {
+		opts.Seed = seed
+		program, err := Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		main := program[strings.Index(program, "int main("):]
+		if strings.Count(main, "(void)func_1(") != 2 || !strings.Contains(main, "klee_assert((crc32_context ^ 0xFFFFFFFFUL) == checksum);") {
+			t.Fatalf("seed %d: main does not compare two runs of func_1:\n%s", seed, main)
+		}
+		if strings.Contains(main, "platform_main_end") {
+			t.Fatalf("seed %d: main still prints the checksum", seed)
+		}
+	}
+}
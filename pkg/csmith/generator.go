@@ -2,20 +2,31 @@ package csmith
 
 import (
 	"fmt"
-	"strings"
+	"math"
+	"os"
+	"strconv"
+
+	"csmith/pkg/csmith/cast"
 )
 
+// level is the nesting depth of an aggregate: 1 when it has no aggregate
+// members.
 type structTypeInfo struct {
 	fields []fieldInfo
+	level  int
+	packed bool
 }
 
 type unionTypeInfo struct {
 	fields []fieldInfo
+	level  int
+	packed bool
 }
 
 type fieldInfo struct {
 	name     string
 	ctype    CType
+	qual     cast.Qual
 	bitfield bool
 	bitWidth int
 }
@@ -27,21 +38,6 @@ type globalInfo struct {
 	isVolatile bool
 }
 
-type arrayInfo struct {
-	name  string
-	ctype CType
-	len   int
-}
-
-type pointerInfo struct {
-	name            string
-	target          string
-	targetTy        CType
-	volatilePointer bool
-	volatileTarget  bool
-	constTarget     bool
-}
-
 type paramInfo struct {
 	name  string
 	ctype CType
@@ -51,23 +47,30 @@ type funcInfo struct {
 	name   string
 	ret    CType
 	params []paramInfo
+	inline bool
+	attrs  []string
 }
 
 type functionFlowState struct {
-	funcs        []funcInfo
-	built        []bool
-	defs         []string
-	maxFuncs     int
-	nextIdx      int
-	nextParamID  int
-	nextLocalID  int
-	pool         []CType
-	info         compositeInfo
-	opts         Options
-	dynGlobals   []globalInfo
-	lateGlobals  strings.Builder
-	nextGlobalID int
-	stmtBudget   int
+	funcs       []funcInfo
+	built       []bool
+	defs        []*cast.FuncDef
+	maxFuncs    int
+	nextIdx     int
+	nextParamID int
+	nextLocalID int
+	pool        []CType
+	info        compositeInfo
+	opts        Options
+	dynGlobals  []globalInfo
+	dynArrays   []arrayInfo
+	dynPointers []pointerInfo
+	// localBindings are global pointers aimed at locals on function entry.
+	localBindings []localBinding
+	lateGlobals   []cast.Decl
+	nextGlobalID  int
+	nextLabelID   int
+	stmtBudget    int
 }
 
 type stmtKind int
@@ -81,6 +84,8 @@ const (
 	stmtBreak
 	stmtGoto
 	stmtArrayOp
+	stmtBlock
+	stmtInvoke
 )
 
 type localInfo struct {
@@ -89,18 +94,19 @@ type localInfo struct {
 }
 
 type scopeInfo struct {
-	params    []paramInfo
-	locals    []localInfo
-	returnVar string
+	params     []paramInfo
+	locals     []localInfo
+	returnVar  string
+	returnType CType
 }
 
 type lvalueInfo struct {
-	expr  string
+	expr  cast.Expr
 	ctype CType
 }
 
 type exprVarCandidate struct {
-	expr       string
+	expr       cast.Expr
 	ctype      CType
 	assignable bool
 }
@@ -111,10 +117,15 @@ type genContext struct {
 	from    int
 	dynLocs []localInfo
 	info    compositeInfo
+	// blocks is the stack of blocks being generated, outermost first; gotos
+	// are the jumps awaiting their labels.
+	blocks []*cast.Block
+	gotos  []gotoSite
 }
 
 type genSnapshot struct {
 	dynLocLen      int
+	gotosLen       int
 	funcsLen       int
 	builtLen       int
 	defsLen        int
@@ -122,9 +133,12 @@ type genSnapshot struct {
 	nextParamID    int
 	nextLocalID    int
 	dynGlobalsLen  int
+	dynArraysLen   int
+	dynPointersLen int
+	bindingsLen    int
 	nextGlobalID   int
 	stmtBudget     int
-	lateGlobalsBuf string
+	lateGlobalsLen int
 }
 
 func takeGenSnapshot(ctx *genContext) *genSnapshot {
@@ -133,6 +147,7 @@ func takeGenSnapshot(ctx *genContext) *genSnapshot {
 	}
 	s := &genSnapshot{
 		dynLocLen: len(ctx.dynLocs),
+		gotosLen:  len(ctx.gotos),
 	}
 	if ctx.state != nil {
 		s.funcsLen = len(ctx.state.funcs)
@@ -142,9 +157,12 @@ func takeGenSnapshot(ctx *genContext) *genSnapshot {
 		s.nextParamID = ctx.state.nextParamID
 		s.nextLocalID = ctx.state.nextLocalID
 		s.dynGlobalsLen = len(ctx.state.dynGlobals)
+		s.dynArraysLen = len(ctx.state.dynArrays)
+		s.dynPointersLen = len(ctx.state.dynPointers)
+		s.bindingsLen = len(ctx.state.localBindings)
 		s.nextGlobalID = ctx.state.nextGlobalID
 		s.stmtBudget = ctx.state.stmtBudget
-		s.lateGlobalsBuf = ctx.state.lateGlobals.String()
+		s.lateGlobalsLen = len(ctx.state.lateGlobals)
 	}
 	return s
 }
@@ -156,6 +174,9 @@ func restoreGenSnapshot(ctx *genContext, s *genSnapshot) {
 	if len(ctx.dynLocs) >= s.dynLocLen {
 		ctx.dynLocs = ctx.dynLocs[:s.dynLocLen]
 	}
+	if len(ctx.gotos) >= s.gotosLen {
+		ctx.gotos = ctx.gotos[:s.gotosLen]
+	}
 	if ctx.state != nil {
 		if len(ctx.state.funcs) >= s.funcsLen {
 			ctx.state.funcs = ctx.state.funcs[:s.funcsLen]
@@ -169,13 +190,23 @@ func restoreGenSnapshot(ctx *genContext, s *genSnapshot) {
 		if len(ctx.state.dynGlobals) >= s.dynGlobalsLen {
 			ctx.state.dynGlobals = ctx.state.dynGlobals[:s.dynGlobalsLen]
 		}
+		if len(ctx.state.dynArrays) >= s.dynArraysLen {
+			ctx.state.dynArrays = ctx.state.dynArrays[:s.dynArraysLen]
+		}
+		if len(ctx.state.dynPointers) >= s.dynPointersLen {
+			ctx.state.dynPointers = ctx.state.dynPointers[:s.dynPointersLen]
+		}
+		if len(ctx.state.localBindings) >= s.bindingsLen {
+			ctx.state.localBindings = ctx.state.localBindings[:s.bindingsLen]
+		}
 		ctx.state.nextIdx = s.nextIdx
 		ctx.state.nextParamID = s.nextParamID
 		ctx.state.nextLocalID = s.nextLocalID
 		ctx.state.nextGlobalID = s.nextGlobalID
 		ctx.state.stmtBudget = s.stmtBudget
-		ctx.state.lateGlobals.Reset()
-		ctx.state.lateGlobals.WriteString(s.lateGlobalsBuf)
+		if len(ctx.state.lateGlobals) >= s.lateGlobalsLen {
+			ctx.state.lateGlobals = ctx.state.lateGlobals[:s.lateGlobalsLen]
+		}
 	}
 }
 
@@ -279,115 +310,180 @@ func max(a, b int) int {
 	return b
 }
 
-func writeLine(b *strings.Builder, indent int, s string) {
-	for i := 0; i < indent; i++ {
-		b.WriteString("    ")
-	}
-	b.WriteString(s)
-	b.WriteByte('\n')
-}
-
-func safeAddExpr(t CType, a, b string, opts Options) string {
-	if !opts.SafeMath {
-		return fmt.Sprintf("((%s) + (%s))", a, b)
-	}
-	sign := "u_u"
-	if t.Signed {
-		sign = "s_s"
-	}
-	bits := t.Bits
-	if bits != 8 && bits != 16 && bits != 32 && bits != 64 {
-		bits = 32
+// unaryOpExpr applies the unary operator op to x of type t. Operators that do
+// not apply to floating-point values, and unary plus when it is disabled,
+// become negation (or "~" on integers).
+func unaryOpExpr(t CType, op string, x cast.Expr, opts Options) cast.Expr {
+	if (op == "~" && t.Float) || (op == "+" && !opts.UnaryPlusOperator) {
+		op = "~"
+		if t.Float {
+			op = "-"
+		}
 	}
-	prefix := "uint"
-	if t.Signed {
-		prefix = "int"
+	if op == "-" {
+		return castExpr(t, safeUnaryMinusExpr(t, x, opts))
 	}
-	return fmt.Sprintf("safe_add_func_%s%d_t_%s(%s, %s)", prefix, bits, sign, a, b)
+	return castExpr(t, paren(&cast.Unary{Op: op, X: paren(x)}))
 }
 
-func safeDivU32Expr(a, b string, opts Options) string {
-	if !opts.SafeMath {
-		return fmt.Sprintf("((%s) / (%s))", a, b)
+// binaryOpExpr combines two values of type t with op, through the safe-math
+// wrapper for arithmetic operators. Operators the options disable or that do
+// not apply to t fall back to "^", or addition for floating-point values.
+func binaryOpExpr(t CType, op string, a, b cast.Expr, opts Options) cast.Expr {
+	if !binaryOpSupported(op, t, opts) {
+		op = "^"
+		if t.Float {
+			op = "+"
+		}
+	}
+	switch op {
+	case "+", "-", "*", "/", "%":
+		return castExpr(t, safeArithExpr(op, t, a, b, opts))
+	case "<<", ">>":
+		return castExpr(t, safeShiftExpr(op, t, a, t, b, opts))
 	}
-	return fmt.Sprintf("safe_div_func_uint32_t_u_u(%s, %s)", a, b)
+	return castExpr(t, paren(binary(paren(a), op, paren(b))))
 }
 
-func safeLShiftU32Expr(a, b string, opts Options) string {
-	if !opts.SafeMath {
-		return fmt.Sprintf("((%s) << (%s))", a, b)
+// xorAssign folds rhs into lhs, both of type t; floating-point values are
+// accumulated by addition instead.
+func xorAssign(lhs cast.Expr, t CType, rhs cast.Expr, opts Options) cast.Stmt {
+	if !t.Float {
+		return exprStmt(assign(lhs, "^=", rhs))
 	}
-	return fmt.Sprintf("safe_lshift_func_uint32_t_u_s(%s, %s)", a, b)
+	return exprStmt(assign(lhs, "=", safeAddExpr(t, lhs, rhs, opts)))
 }
 
-func safeRShiftU32Expr(a, b string, opts Options) string {
-	if !opts.SafeMath {
-		return fmt.Sprintf("((%s) >> (%s))", a, b)
+// assignExpr builds the embedded assignment "(T)(c = rhs)" where rhs has type
+// t, converting between integer and floating-point types where they differ.
+func assignExpr(c exprVarCandidate, t CType, rhs cast.Expr, opts Options) cast.Expr {
+	if !c.ctype.Float && !t.Float {
+		return castExpr(t, paren(assign(c.expr, "=", rhs)))
 	}
-	return fmt.Sprintf("safe_rshift_func_uint32_t_u_s(%s, %s)", a, b)
+	return convertExpr(c.ctype, t, paren(assign(c.expr, "=", convertExpr(t, c.ctype, rhs, opts))), opts)
 }
 
-func randomRawLiteral(t CType, r *rng) string {
+func randomRawLiteral(t CType, r *rng) cast.Expr {
 	switch {
 	case t.Bits <= 8:
-		return fmt.Sprintf("0x%02Xu", r.next31()&0xFF)
+		return lit("0x%02Xu", r.next31()&0xFF)
 	case t.Bits <= 16:
-		return fmt.Sprintf("0x%04Xu", r.next31()&0xFFFF)
+		return lit("0x%04Xu", r.next31()&0xFFFF)
 	case t.Bits <= 32:
-		return fmt.Sprintf("0x%08Xu", r.next31())
+		return lit("0x%08Xu", r.next31())
 	default:
-		return fmt.Sprintf("0x%08X%08XULL", r.next31(), r.next31())
+		return lit("0x%08X%08XULL", r.next31(), r.next31())
 	}
 }
 
-func randomConstantExpr(t CType, r *rng, opts Options) string {
+func randomConstantExpr(t CType, r *rng, opts Options) cast.Expr {
+	if t.Float {
+		return floatConstantExpr(t, r.next31, opts)
+	}
 	if t.Bits <= 8 {
-		return castLiteral(t, fmt.Sprintf("0x%02X", r.next31()&0xFF))
+		return castExpr(t, lit("0x%02X", r.next31()&0xFF))
 	}
 	if t.Bits <= 16 {
-		return castLiteral(t, fmt.Sprintf("0x%04X", r.next31()&0xFFFF))
+		return castExpr(t, lit("0x%04X", r.next31()&0xFFFF))
 	}
 	if t.Bits <= 32 {
 		suffix := "U"
 		if t.Signed {
 			suffix = "L"
 		}
-		return castLiteral(t, fmt.Sprintf("0x%08X%s", r.next31(), suffix))
+		return castExpr(t, lit("0x%08X%s", r.next31(), suffix))
+	}
+	if t.Bits == 128 {
+		return int128ConstantExpr(t, r.next31)
 	}
 	if opts.LongLong {
 		if t.Signed {
-			return castLiteral(t, fmt.Sprintf("0x%08X%08XLL", r.next31(), r.next31()))
+			return castExpr(t, lit("0x%08X%08XLL", r.next31(), r.next31()))
 		}
-		return castLiteral(t, fmt.Sprintf("0x%08X%08XULL", r.next31(), r.next31()))
+		return castExpr(t, lit("0x%08X%08XULL", r.next31(), r.next31()))
 	}
-	return castLiteral(t, fmt.Sprintf("0x%08X%08X", r.next31(), r.next31()))
+	return castExpr(t, lit("0x%08X%08X", r.next31(), r.next31()))
 }
 
-func randomConstantExprFromER(t CType, er *exprRand, opts Options) string {
+func randomConstantExprFromER(t CType, er *exprRand, opts Options) cast.Expr {
+	if t.Float {
+		return floatConstantExpr(t, er.next, opts)
+	}
 	if t.Bits <= 8 {
-		return castLiteral(t, fmt.Sprintf("0x%02X", er.next()&0xFF))
+		return castExpr(t, lit("0x%02X", er.next()&0xFF))
 	}
 	if t.Bits <= 16 {
-		return castLiteral(t, fmt.Sprintf("0x%04X", er.next()&0xFFFF))
+		return castExpr(t, lit("0x%04X", er.next()&0xFFFF))
 	}
 	if t.Bits <= 32 {
 		suffix := "U"
 		if t.Signed {
 			suffix = "L"
 		}
-		return castLiteral(t, fmt.Sprintf("0x%08X%s", er.next(), suffix))
+		return castExpr(t, lit("0x%08X%s", er.next(), suffix))
+	}
+	if t.Bits == 128 {
+		return int128ConstantExpr(t, er.next)
 	}
 	if opts.LongLong {
 		if t.Signed {
-			return castLiteral(t, fmt.Sprintf("0x%08X%08XLL", er.next(), er.next()))
+			return castExpr(t, lit("0x%08X%08XLL", er.next(), er.next()))
 		}
-		return castLiteral(t, fmt.Sprintf("0x%08X%08XULL", er.next(), er.next()))
+		return castExpr(t, lit("0x%08X%08XULL", er.next(), er.next()))
 	}
-	return castLiteral(t, fmt.Sprintf("0x%08X%08X", er.next(), er.next()))
+	return castExpr(t, lit("0x%08X%08X", er.next(), er.next()))
+}
+
+// int128ConstantExpr builds a 128-bit constant of type t. C has no 128-bit
+// literals, so the value is assembled from two 64-bit halves:
+// "((T)((((unsigned __int128)(hi)) << 64) | lo))".
+func int128ConstantExpr(t CType, next func() uint32) cast.Expr {
+	u128 := CType{Name: "unsigned __int128", Bits: 128}
+	hi := lit("0x%08X%08XULL", next(), next())
+	lo := lit("0x%08X%08XULL", next(), next())
+	return castExpr(t, binary(paren(binary(castExpr(u128, hi), "<<", lit("64"))), "|", lo))
+}
+
+// floatConstantExpr builds a hexadecimal floating-point constant of type t,
+// drawing as many random words as an integer constant of the same width.
+// C++ only has hexadecimal floating literals from C++17 on, so C++ programs
+// spell the same value in decimal.
+func floatConstantExpr(t CType, next func() uint32, opts Options) cast.Expr {
+	if t.Bits <= 32 {
+		v := next()
+		frac, exp := v&0xFFFFF, int(v>>20&0x1F)-16
+		if opts.LangCPP {
+			f := math.Ldexp(1+float64(frac)/(1<<20), exp)
+			return castExpr(t, lit("%sF", strconv.FormatFloat(f, 'e', -1, 32)))
+		}
+		return castExpr(t, lit("0x1.%05Xp%+dF", frac, exp))
+	}
+	hi, lo := next(), next()
+	exp := int(hi&0x7F) - 64
+	if opts.LangCPP {
+		f := math.Ldexp(1+float64(lo)/(1<<32), exp)
+		return castExpr(t, lit("%s", strconv.FormatFloat(f, 'e', -1, 64)))
+	}
+	return castExpr(t, lit("0x1.%08Xp%+d", lo, exp))
 }
 
 func sameBaseType(a, b CType) bool {
-	return a.Bits == b.Bits && a.Signed == b.Signed
+	return a.Bits == b.Bits && a.Signed == b.Signed && a.Float == b.Float
+}
+
+// strictFloatCandidates drops the candidates whose floating-point-ness differs
+// from t when --strict-float forbids mixing integer and floating-point values.
+func strictFloatCandidates(t CType, candidates []exprVarCandidate, opts Options) []exprVarCandidate {
+	if !opts.StrictFloat {
+		return candidates
+	}
+	out := make([]exprVarCandidate, 0, len(candidates))
+	for _, c := range candidates {
+		if c.ctype.Float == t.Float {
+			out = append(out, c)
+		}
+	}
+	return out
 }
 
 func mergedLocals(scope scopeInfo, ctx *genContext) []localInfo {
@@ -413,24 +509,24 @@ func mergedGlobals(env envInfo, ctx *genContext) []globalInfo {
 func buildExprCandidates(r *rng, env envInfo, scope scopeInfo, ctx *genContext) []exprVarCandidate {
 	candidates := make([]exprVarCandidate, 0, len(env.globals)+len(scope.params)+len(scope.locals)+len(env.pointers)+len(env.arrays))
 	for _, g := range mergedGlobals(env, ctx) {
-		candidates = append(candidates, exprVarCandidate{expr: g.name, ctype: g.ctype, assignable: !g.isConst})
+		candidates = append(candidates, varCandidates(ident(g.name), g.ctype, !g.isConst, ctx)...)
 	}
 	for _, p := range scope.params {
-		candidates = append(candidates, exprVarCandidate{expr: p.name, ctype: p.ctype, assignable: true})
+		candidates = append(candidates, varCandidates(ident(p.name), p.ctype, true, ctx)...)
 	}
 	for _, l := range mergedLocals(scope, ctx) {
 		// Synthetic accumulator local does not exist in upstream variable pools.
 		if l.name == "x" {
 			continue
 		}
-		candidates = append(candidates, exprVarCandidate{expr: l.name, ctype: l.ctype, assignable: true})
+		candidates = append(candidates, varCandidates(ident(l.name), l.ctype, true, ctx)...)
 	}
-	for _, p := range env.pointers {
-		candidates = append(candidates, exprVarCandidate{expr: "*" + p.name, ctype: p.targetTy, assignable: !p.constTarget})
+	for _, p := range mergedPointers(env, ctx) {
+		candidates = append(candidates, exprVarCandidate{expr: p.derefExpr(), ctype: p.targetTy, assignable: p.assignable()})
 	}
-	for _, arr := range env.arrays {
+	for _, arr := range mergedArrays(env, ctx) {
 		candidates = append(candidates, exprVarCandidate{
-			expr:       fmt.Sprintf("%s[%d]", arr.name, int(r.upto(uint32(arr.len)))),
+			expr:       arr.randomAccess(r.upto),
 			ctype:      arr.ctype,
 			assignable: true,
 		})
@@ -441,23 +537,23 @@ func buildExprCandidates(r *rng, env envInfo, scope scopeInfo, ctx *genContext)
 func buildExprCandidatesFromER(er *exprRand, env envInfo, scope scopeInfo, ctx *genContext) []exprVarCandidate {
 	candidates := make([]exprVarCandidate, 0, len(env.globals)+len(scope.params)+len(scope.locals)+len(env.pointers)+len(env.arrays))
 	for _, g := range mergedGlobals(env, ctx) {
-		candidates = append(candidates, exprVarCandidate{expr: g.name, ctype: g.ctype, assignable: !g.isConst})
+		candidates = append(candidates, varCandidates(ident(g.name), g.ctype, !g.isConst, ctx)...)
 	}
 	for _, p := range scope.params {
-		candidates = append(candidates, exprVarCandidate{expr: p.name, ctype: p.ctype, assignable: true})
+		candidates = append(candidates, varCandidates(ident(p.name), p.ctype, true, ctx)...)
 	}
 	for _, l := range mergedLocals(scope, ctx) {
 		if l.name == "x" {
 			continue
 		}
-		candidates = append(candidates, exprVarCandidate{expr: l.name, ctype: l.ctype, assignable: true})
+		candidates = append(candidates, varCandidates(ident(l.name), l.ctype, true, ctx)...)
 	}
-	for _, p := range env.pointers {
-		candidates = append(candidates, exprVarCandidate{expr: "*" + p.name, ctype: p.targetTy, assignable: !p.constTarget})
+	for _, p := range mergedPointers(env, ctx) {
+		candidates = append(candidates, exprVarCandidate{expr: p.derefExpr(), ctype: p.targetTy, assignable: p.assignable()})
 	}
-	for _, arr := range env.arrays {
+	for _, arr := range mergedArrays(env, ctx) {
 		candidates = append(candidates, exprVarCandidate{
-			expr:       fmt.Sprintf("%s[%d]", arr.name, int(er.pick(uint32(arr.len)))),
+			expr:       arr.randomAccess(er.pick),
 			ctype:      arr.ctype,
 			assignable: true,
 		})
@@ -496,27 +592,27 @@ func buildScopedCandidatesFromER(er *exprRand, env envInfo, scope scopeInfo, sco
 	switch scopePick {
 	case 0:
 		for _, g := range mergedGlobals(env, ctx) {
-			out = append(out, exprVarCandidate{expr: g.name, ctype: g.ctype, assignable: !g.isConst})
+			out = append(out, varCandidates(ident(g.name), g.ctype, !g.isConst, ctx)...)
 		}
 	case 1:
 		for _, l := range mergedLocals(scope, ctx) {
 			if l.name == "x" {
 				continue
 			}
-			out = append(out, exprVarCandidate{expr: l.name, ctype: l.ctype, assignable: true})
+			out = append(out, varCandidates(ident(l.name), l.ctype, true, ctx)...)
 		}
 	case 2:
 		for _, p := range scope.params {
-			out = append(out, exprVarCandidate{expr: p.name, ctype: p.ctype, assignable: true})
+			out = append(out, varCandidates(ident(p.name), p.ctype, true, ctx)...)
 		}
 	}
 	if scopePick != 2 {
-		for _, ptr := range env.pointers {
-			out = append(out, exprVarCandidate{expr: "*" + ptr.name, ctype: ptr.targetTy, assignable: !ptr.constTarget})
+		for _, ptr := range mergedPointers(env, ctx) {
+			out = append(out, exprVarCandidate{expr: ptr.derefExpr(), ctype: ptr.targetTy, assignable: ptr.assignable()})
 		}
-		for _, arr := range env.arrays {
+		for _, arr := range mergedArrays(env, ctx) {
 			out = append(out, exprVarCandidate{
-				expr:       fmt.Sprintf("%s[%d]", arr.name, int(er.pick(uint32(arr.len)))),
+				expr:       arr.randomAccess(er.pick),
 				ctype:      arr.ctype,
 				assignable: true,
 			})
@@ -532,24 +628,33 @@ func createOnDemandGlobalFromER(er *exprRand, opts Options, t CType, ctx *genCon
 	id := ctx.state.nextGlobalID
 	ctx.state.nextGlobalID = id + 1
 	name := fmt.Sprintf("g_%d", id)
-	// Upstream defaults: regular const 10%, regular volatile 50% (when enabled).
-	isConst := opts.Consts && er.pick(100) < 10
-	isVolatile := opts.Volatiles && er.pick(100) < 50
+	// Upstream RegularConstProb / RegularVolatileProb (when enabled).
+	isConst := opts.Consts && er.pick(100) < opts.prob(probRegularConst)
+	isVolatile := opts.Volatiles && er.pick(100) < opts.prob(probRegularVolatile)
 	if isConst && isVolatile && er.pick(2) == 0 {
 		isConst = false
 	}
-	qual := ""
-	if isConst {
-		qual += "const "
-	}
-	if isVolatile {
-		qual += "volatile "
+	if t.Aggregate && (opts.LangCPP || opts.CComp) {
+		// The implicit copy operations of a C++ class do not accept volatile
+		// operands, and CompCert does not support volatile accesses to whole
+		// structs and unions, so neither makes them volatile.
+		isVolatile = false
 	}
-	lit := randomConstantExprFromER(t, er, opts)
-	writeLine(&ctx.state.lateGlobals, 0, fmt.Sprintf("static %s%s %s = %s;", qual, t.Name, name, lit))
+	var init cast.Expr
+	if t.Aggregate {
+		init = aggregateInitExpr(t, ctx.info, func(ft CType) cast.Expr { return randomConstantExprFromER(ft, er, opts) })
+	} else {
+		init = randomConstantExprFromER(t, er, opts)
+	}
+	ctx.state.lateGlobals = append(ctx.state.lateGlobals, &cast.VarDecl{
+		Storage: "static",
+		Type:    qualTypeNode(t, isConst, isVolatile),
+		Name:    name,
+		Init:    init,
+	})
 	g := globalInfo{name: name, ctype: t, isConst: isConst, isVolatile: isVolatile}
 	ctx.state.dynGlobals = append(ctx.state.dynGlobals, g)
-	return exprVarCandidate{expr: name, ctype: t, assignable: !isConst}, true
+	return exprVarCandidate{expr: ident(name), ctype: t, assignable: !isConst}, true
 }
 
 func createOnDemandFromParentLocalPathER(er *exprRand, opts Options, t CType, ctx *genContext) (exprVarCandidate, bool) {
@@ -560,12 +665,18 @@ func createOnDemandFromParentLocalPathER(er *exprRand, opts Options, t CType, ct
 	// choose_random_simple() and consumes rnd_upto(MAX_SIMPLE_TYPES=14).
 	chosen := t
 	if t.Bits > 0 && len(ctx.state.pool) > 0 {
-		i := int(er.fallback.upto(14))
+		pool := ctx.state.pool
+		i := int(er.fallback.uptoWithFilter(14, func(v uint32) bool {
+			return !typeEnabled(pool[int(v)%len(pool)], opts)
+		}))
 		if i >= 0 && i < len(ctx.state.pool) {
 			chosen = ctx.state.pool[i]
 		} else {
 			chosen = ctx.state.pool[i%len(ctx.state.pool)]
 		}
+		if opts.StrictFloat && chosen.Float != t.Float {
+			chosen = t
+		}
 	}
 
 	// Upstream GenerateNewParentLocal path:
@@ -580,17 +691,22 @@ func createOnDemandFromParentLocalPathER(er *exprRand, opts Options, t CType, ct
 	//      - pure_rnd_flipcoin(50) -> F 50  (or pure_rnd_upto depending on branch)
 	//      - pure_rnd_upto(20)     -> U 20
 	// After this, upstream returns the local variable with NO further main RNG use.
-	_ = er.fallback.flipcoin(50) // volatile from random_qualifiers
-	_ = er.fallback.flipcoin(10) // const from random_qualifiers
-	_ = er.fallback.flipcoin(20) // create_and_initialize NewArrayVariableProb
-	_ = er.fallback.flipcoin(50) // pure_rnd_flipcoin(50) in GenerateRandomConstant
-	_ = er.fallback.flipcoin(50) // pure_rnd_flipcoin(50) in GenerateRandomConstant
-	_ = er.fallback.upto(20)     // pure_rnd_upto(20) in GenerateRandomConstant
+	_ = er.fallback.flipcoin(opts.prob(probRegularVolatile))         // volatile from random_qualifiers
+	_ = er.fallback.flipcoin(opts.prob(probRegularConst))            // const from random_qualifiers
+	isArray := er.fallback.flipcoin(opts.prob(probNewArrayVariable)) // create_and_initialize NewArrayVariableProb
+	_ = er.fallback.flipcoin(50)                                     // pure_rnd_flipcoin(50) in GenerateRandomConstant
+	_ = er.fallback.flipcoin(50)                                     // pure_rnd_flipcoin(50) in GenerateRandomConstant
+	_ = er.fallback.upto(20)                                         // pure_rnd_upto(20) in GenerateRandomConstant
 
 	// Materialize as a generated global in our simplified backend WITHOUT
 	// consuming any more main RNG. The upstream's local variable creation
 	// used pure_rnd for const/volatile (already consumed above) and
 	// pure_rnd for the init constant (also consumed above as the upto(20)).
+	// Arrays additionally draw their dimensions, element kind and the
+	// element selected for this access.
+	if isArray && opts.Arrays {
+		return createArrayGlobal(er.fallback, opts, chosen, randomArrayDims(er.fallback, opts), ctx)
+	}
 	return createLocalPathGlobalDirect(opts, chosen, ctx)
 }
 
@@ -607,14 +723,19 @@ func createLocalPathGlobalDirect(opts Options, t CType, ctx *genContext) (exprVa
 	ctx.state.nextGlobalID = id + 1
 	name := fmt.Sprintf("g_%d", id)
 	// Use a zero literal — no main RNG consumption.
-	writeLine(&ctx.state.lateGlobals, 0, fmt.Sprintf("static %s %s = 0;", t.Name, name))
+	ctx.state.lateGlobals = append(ctx.state.lateGlobals, &cast.VarDecl{
+		Storage: "static",
+		Type:    typeNode(t),
+		Name:    name,
+		Init:    lit("0"),
+	})
 	g := globalInfo{name: name, ctype: t, isConst: false, isVolatile: false}
 	ctx.state.dynGlobals = append(ctx.state.dynGlobals, g)
 	// Also add to dynLocs so mergedLocals() finds this variable on subsequent
 	// selections — matches upstream's GenerateNewParentLocal adding to
 	// block->local_vars, preventing repeated on-demand creation.
 	ctx.dynLocs = append(ctx.dynLocs, localInfo{name: name, ctype: t})
-	return exprVarCandidate{expr: name, ctype: t, assignable: true}, true
+	return exprVarCandidate{expr: ident(name), ctype: t, assignable: true}, true
 }
 
 func selectExprVariable(t CType, r *rng, candidates []exprVarCandidate, forAssign bool) (exprVarCandidate, bool) {
@@ -636,7 +757,7 @@ func selectExprVariable(t CType, r *rng, candidates []exprVarCandidate, forAssig
 			exact = append(exact, c)
 			continue
 		}
-		if c.ctype.Bits == t.Bits {
+		if c.ctype.Bits == t.Bits && c.ctype.Float == t.Float {
 			sameWidth = append(sameWidth, c)
 		}
 	}
@@ -668,7 +789,7 @@ func selectExprVariableFromER(t CType, er *exprRand, candidates []exprVarCandida
 			exact = append(exact, c)
 			continue
 		}
-		if c.ctype.Bits == t.Bits {
+		if c.ctype.Bits == t.Bits && c.ctype.Float == t.Float {
 			sameWidth = append(sameWidth, c)
 		}
 	}
@@ -700,9 +821,9 @@ func buildFunctionCallExpr(
 	scope scopeInfo,
 	depth int,
 	ctx *genContext,
-) (string, bool) {
+) (cast.Expr, bool) {
 	if ctx == nil || ctx.state == nil {
-		return "", false
+		return nil, false
 	}
 	state := ctx.state
 	from := ctx.from
@@ -712,6 +833,10 @@ func buildFunctionCallExpr(
 		if i <= from {
 			continue
 		}
+		if t.Aggregate && state.funcs[i].ret.Name != t.Name {
+			// Aggregates cannot be converted; only exact returns qualify.
+			continue
+		}
 		candidates = append(candidates, i)
 	}
 
@@ -721,12 +846,20 @@ func buildFunctionCallExpr(
 	} else {
 		useExisting = er.pick(2) == 0
 	}
+	useBuiltin := false
 	if er != nil && er.fallback != nil {
 		p := uint32(0)
 		if opts.Builtins {
-			p = uint32(opts.BuiltinFunctionProb)
+			p = opts.prob(probBuiltinFunction)
+		}
+		useBuiltin = er.fallback.flipcoin(p)
+	}
+	if useBuiltin && !t.Aggregate {
+		snap := takeGenSnapshot(ctx)
+		if b, ok := buildBuiltinCallExpr(t, er, opts, env, scope, depth, ctx); ok {
+			return b, true
 		}
-		_ = er.fallback.flipcoin(p)
+		restoreGenSnapshot(ctx, snap)
 	}
 	var callee funcInfo
 	calleeIdx := -1
@@ -737,7 +870,7 @@ func buildFunctionCallExpr(
 		if len(candidates) == 0 {
 			// Upstream often fails this path early when no callable function is
 			// available in context; caller retries another expression form.
-			return "", false
+			return nil, false
 		}
 		created, newIdx, ok := state.appendNewFunction(er.fallback, &t)
 		if ok {
@@ -761,22 +894,21 @@ func buildFunctionCallExpr(
 			calleeIdx = candidates[int(er.pick(uint32(len(candidates))))]
 			callee = state.funcs[calleeIdx]
 		} else {
-			return "", false
+			return nil, false
 		}
 	}
 
-	args := "void"
-	if len(callee.params) > 0 {
-		argExprs := make([]string, 0, len(callee.params))
-		for _, p := range callee.params {
-			argExprs = append(argExprs, randomParamExprDepth(p.ctype, er, opts, env, scope, depth+1, ctx))
-		}
-		args = strings.Join(argExprs, ", ")
+	args := make([]cast.Expr, 0, len(callee.params))
+	for _, p := range callee.params {
+		args = append(args, randomParamExprDepth(p.ctype, er, opts, env, scope, depth+1, ctx))
 	}
-	if args == "void" {
-		return castLiteral(t, fmt.Sprintf("%s()", callee.name)), true
+	switch {
+	case t.Aggregate:
+		return call(callee.name, args...), true
+	case callee.ret.Aggregate:
+		return aggregateMemberValue(callee.ret, t, call(callee.name, args...), ctx.info, opts), true
 	}
-	return castLiteral(t, fmt.Sprintf("%s(%s)", callee.name, args)), true
+	return convertExpr(callee.ret, t, call(callee.name, args...), opts), true
 }
 
 func randomLeafExprWithMode(
@@ -790,7 +922,10 @@ func randomLeafExprWithMode(
 	isParam bool,
 	noFunc bool,
 	noConst bool,
-) string {
+) cast.Expr {
+	if t.Aggregate {
+		return aggregateExpr(t, er, opts, env, scope, depth, ctx)
+	}
 	type termChoice int
 	const (
 		termFunction termChoice = iota
@@ -805,6 +940,8 @@ func randomLeafExprWithMode(
 		prob int
 	}
 	entries := make([]termEntry, 0, 5)
+	// Upstream hard-codes the term weights (Expression::make_random) rather
+	// than reading them from its probability table.
 	funcW := 70
 	varW := 20
 	constW := 10
@@ -895,26 +1032,42 @@ func randomLeafExprWithMode(
 			if depth > 0 && er != nil && er.fallback != nil {
 				// ExpressionFuncall::ExpressionFunctionProbability + stdfunc path.
 				if er.fallback.flipcoin(80) {
-					if er.fallback.flipcoin(5) {
-						_ = er.fallback.flipcoin(50)
-						_ = er.fallback.upto(4)
+					if er.fallback.flipcoin(opts.prob(probStdUnaryFunc)) {
+						// SafeOpFlags::make_random_unary draws the wrapper's
+						// signedness; the wrapper here follows the operand
+						// type, so the draw only keeps the stream in step.
+						_ = er.fallback.flipcoin(opts.prob(probSafeOpsSigned))
+						op := unaryOps[er.fallback.uptoWithFilter(uint32(len(unaryOps)), opts.groupFilter(probUnaryPlus))]
 						// Recursive child expression must advance depth.
 						operand := randomTypedExprDepthFlags(t, er, opts, env, scope, depth+1, ctx, false, false)
-						return castLiteral(t, fmt.Sprintf("(~(%s))", operand))
+						return unaryOpExpr(t, op, operand, opts)
 					}
 					ptrCmpProb := uint32(0)
 					if opts.Pointers {
 						ptrCmpProb = 10
 					}
-					_ = er.fallback.flipcoin(ptrCmpProb)
-					_ = er.fallback.upto(18)
-					_ = er.fallback.flipcoin(50)
-					_ = er.fallback.flipcoin(50)
-					_ = er.fallback.upto(4)
+					ptrCmp := er.fallback.flipcoin(ptrCmpProb)
+					op := er.fallback.uptoWithFilter(uint32(len(binaryOps)), opts.groupFilter(probBinaryAdd))
+					// SafeOpFlags::make_random_binary: operand signedness
+					// and size, drawn for the stream only as above.
+					_ = er.fallback.flipcoin(opts.prob(probSafeOpsSigned))
+					_ = er.fallback.flipcoin(opts.prob(probSafeOpsSigned))
+					_ = er.fallback.uptoWithFilter(4, opts.groupFilter(probSafeOpsSizeInt8))
+					if ptrCmp {
+						if cmp, ok := pointerCompareExpr(t, er.fallback, op, opts, env, ctx); ok {
+							return cmp
+						}
+					}
 					// Recursive child expressions must advance depth.
 					lhs := randomTypedExprDepthFlags(t, er, opts, env, scope, depth+1, ctx, false, false)
-					rhs := randomTypedExprDepthFlags(t, er, opts, env, scope, depth+1, ctx, false, false)
-					return castLiteral(t, fmt.Sprintf("((%s) ^ (%s))", lhs, rhs))
+					var rhs cast.Expr
+					if shift := binaryOps[op] == "<<" || binaryOps[op] == ">>"; shift && !t.Float && !er.fallback.flipcoin(opts.prob(probShiftByNonConstant)) {
+						// Shift by a constant within the width of t.
+						rhs = castExpr(t, lit("%d", er.fallback.upto(uint32(t.Bits))))
+					} else {
+						rhs = randomTypedExprDepthFlags(t, er, opts, env, scope, depth+1, ctx, false, false)
+					}
+					return binaryOpExpr(t, binaryOps[op], lhs, rhs, opts)
 				}
 			}
 			if depth < maxExprDepth(opts) {
@@ -927,7 +1080,7 @@ func randomLeafExprWithMode(
 			scopePick := variableScopePickFromER(er, opts)
 			if scopePick == 3 {
 				if g, ok := createOnDemandGlobalFromER(er, opts, t, ctx); ok {
-					return castLiteral(t, g.expr)
+					return castExpr(t, g.expr)
 				}
 				restoreGenSnapshot(ctx, snap)
 				continue
@@ -940,19 +1093,20 @@ func randomLeafExprWithMode(
 			if len(candidates) == 0 {
 				if scopePick == 0 {
 					if g, ok := createOnDemandGlobalFromER(er, opts, t, ctx); ok {
-						return castLiteral(t, g.expr)
+						return castExpr(t, g.expr)
 					}
 				}
 				if scopePick == 1 {
 					if g, ok := createOnDemandFromParentLocalPathER(er, opts, t, ctx); ok {
-						return castLiteral(t, g.expr)
+						return convertExpr(g.ctype, t, g.expr, opts)
 					}
 				}
 				candidates = buildExprCandidatesFromER(er, env, scope, ctx)
 			}
+			candidates = strictFloatCandidates(t, candidates, opts)
 			if len(candidates) > 0 {
 				if c, ok := selectExprVariableFromER(t, er, candidates, false); ok {
-					return castLiteral(t, c.expr)
+					return convertExpr(c.ctype, t, c.expr, opts)
 				}
 			}
 			restoreGenSnapshot(ctx, snap)
@@ -968,8 +1122,8 @@ func randomLeafExprWithMode(
 			//    b. Full Expression::make_random for RHS (recursive)
 			//    c. Lhs::make_random for LHS variable selection
 			if er != nil && er.fallback != nil {
-				_ = er.fallback.flipcoin(50) // CVQualifiers volatile draw
-				_ = er.fallback.upto(120)    // AssignOpsProbability
+				_ = er.fallback.flipcoin(opts.prob(probRegularVolatile)) // CVQualifiers volatile draw
+				_ = er.fallback.upto(120)                                // AssignOpsProbability
 			}
 			// Generate full RHS expression (upstream does Expression::make_random
 			// before LHS selection).
@@ -988,15 +1142,16 @@ func randomLeafExprWithMode(
 			// Upstream Lhs::make_random (Lhs.cpp:61): do-while loop that tries
 			// select_deref_pointer before falling through to VariableSelector::select.
 			lhsFromDeref := false
+			var hexDigits string
 			if er != nil && er.fallback != nil {
 				for {
-					deref := er.fallback.flipcoin(80) // SelectDerefPointerProb (Lhs.cpp:78)
+					deref := er.fallback.flipcoin(opts.prob(probSelectDerefPointer)) // SelectDerefPointerProb (Lhs.cpp:78)
 					if !deref {
 						break // fall through to VariableSelector::select
 					}
 					// select_deref_pointer: no pointer vars -> GenerateNewParentLocal
 					// create_and_initialize (VariableSelector.cpp:510):
-					_ = er.fallback.flipcoin(20) // NewArrayVariableProb
+					_ = er.fallback.flipcoin(opts.prob(probNewArrayVariable)) // NewArrayVariableProb
 					// make_init_value for pointer (VariableSelector.cpp:834):
 					initConst := er.fallback.flipcoin(20)
 					if initConst {
@@ -1007,28 +1162,41 @@ func randomLeafExprWithMode(
 					}
 					// Address-of path: create global int for pointer target
 					// GenerateNewGlobal -> create_and_initialize for int:
-					_ = er.fallback.flipcoin(20) // inner NewArrayVariableProb
+					_ = er.fallback.flipcoin(opts.prob(probNewArrayVariable)) // inner NewArrayVariableProb
 					// Constant::make_random(int) -> GenerateRandomConstant:
 					_ = er.fallback.flipcoin(50) // pure_rnd_flipcoin(50)
-					for i := 0; i < 8; i++ {
-						_ = er.fallback.next31() // RandomHexDigits(8)
+					digits := make([]byte, 8)
+					for i := range digits {
+						digits[i] = "0123456789ABCDEF"[er.fallback.next31()%16] // RandomHexDigits(8)
 					}
+					hexDigits = string(digits)
 					// Pointer to valid var -> opportunistic_validate passes -> exit
 					lhsFromDeref = true
 					break
 				}
 			}
+			if lhsFromDeref && opts.Pointers {
+				// The new pointer's target gets the constant drawn above; the
+				// assignment goes through the full chain of dereferences.
+				if target, ok := choosePointerTarget(er.fallback, opts, env, scope, ctx, hexDigits); ok {
+					p := createPointerChain(er.fallback, opts, target, ctx)
+					c := exprVarCandidate{expr: p.derefExpr(), ctype: p.targetTy, assignable: p.assignable()}
+					if c.assignable && len(strictFloatCandidates(t, []exprVarCandidate{c}, opts)) > 0 {
+						return assignExpr(c, t, rhs, opts)
+					}
+				}
+			}
 			if lhsFromDeref {
 				// Upstream Lhs.cpp:82-86: when select_deref_pointer returns valid var,
 				// skips VariableSelector::select entirely (zero RNG consumed).
 				// Pick first assignable candidate without consuming RNG.
 				candidates := buildExprCandidatesFromER(er, env, scope, ctx)
-				for _, c := range candidates {
+				for _, c := range strictFloatCandidates(t, candidates, opts) {
 					if c.assignable {
-						return castLiteral(t, fmt.Sprintf("(%s = %s)", c.expr, rhs))
+						return assignExpr(c, t, rhs, opts)
 					}
 				}
-				return castLiteral(t, fmt.Sprintf("(%s)", rhs))
+				return castExpr(t, paren(rhs))
 			}
 			// VariableSelector::select (VariableSelector.cpp:1187): scope pick.
 			scopePick := variableScopePickFromER(er, opts)
@@ -1036,49 +1204,41 @@ func randomLeafExprWithMode(
 			if len(candidates) == 0 {
 				if scopePick == 0 || scopePick == 3 {
 					if g, ok := createOnDemandGlobalFromER(er, opts, t, ctx); ok {
-						return castLiteral(t, fmt.Sprintf("(%s = %s)", g.expr, rhs))
+						return castExpr(t, paren(assign(g.expr, "=", rhs)))
 					}
 				}
 				candidates = buildExprCandidatesFromER(er, env, scope, ctx)
 			}
+			candidates = strictFloatCandidates(t, candidates, opts)
 			if len(candidates) > 0 {
 				if lv, ok := selectExprVariableFromER(t, er, candidates, true); ok {
-					return castLiteral(t, fmt.Sprintf("(%s = %s)", lv.expr, rhs))
+					return assignExpr(lv, t, rhs, opts)
 				}
 			}
 			restoreGenSnapshot(ctx, snap)
 		case termComma:
 			lhsType := t
 			if er != nil && er.fallback != nil && ctx != nil && ctx.state != nil {
-				allCount := len(ctx.state.pool) + len(ctx.state.info.structs) + len(ctx.state.info.unions)
-				if allCount > 0 {
-					pick := int(er.fallback.upto(uint32(allCount)))
-					switch {
-					case pick < len(ctx.state.pool):
-						lhsType = ctx.state.pool[pick]
-					case pick < len(ctx.state.pool)+len(ctx.state.info.structs):
-						lhsType = CType{Name: fmt.Sprintf("struct S%d", pick-len(ctx.state.pool)), Bits: 32}
-					default:
-						lhsType = CType{Name: fmt.Sprintf("union U%d", pick-len(ctx.state.pool)-len(ctx.state.info.structs)), Bits: 32}
-					}
+				if len(ctx.state.pool) > 0 {
+					lhsType = pickAnyType(er.fallback, opts, ctx.state.pool, ctx.state.info, true, true)
 				}
 			}
 			// Upstream ExpressionComma::make_random:
 			// lhs = make_random(..., type=nil, no_const=true), rhs = make_random(..., type=t)
 			lhs := randomTypedExprDepthFlags(lhsType, er, opts, env, scope, depth+1, ctx, false, true)
 			rhs := randomTypedExprDepthFlags(t, er, opts, env, scope, depth+1, ctx, false, false)
-			return castLiteral(t, fmt.Sprintf("((%s), (%s))", lhs, rhs))
+			return castExpr(t, paren(&cast.Comma{X: paren(lhs), Y: paren(rhs)}))
 		}
 	}
 
 	return randomConstantExprFromER(t, er, opts)
 }
 
-func randomLeafExpr(t CType, er *exprRand, opts Options, env envInfo, scope scopeInfo, depth int, ctx *genContext) string {
+func randomLeafExpr(t CType, er *exprRand, opts Options, env envInfo, scope scopeInfo, depth int, ctx *genContext) cast.Expr {
 	return randomLeafExprWithMode(t, er, opts, env, scope, depth, ctx, false, false, false)
 }
 
-func randomParamLeafExpr(t CType, er *exprRand, opts Options, env envInfo, scope scopeInfo, depth int, ctx *genContext) string {
+func randomParamLeafExpr(t CType, er *exprRand, opts Options, env envInfo, scope scopeInfo, depth int, ctx *genContext) cast.Expr {
 	return randomLeafExprWithMode(t, er, opts, env, scope, depth, ctx, true, false, false)
 }
 
@@ -1091,17 +1251,17 @@ func maxExprDepth(opts Options) int {
 	return opts.MaxExprComplexity
 }
 
-func randomTypedExprDepth(t CType, er *exprRand, opts Options, env envInfo, scope scopeInfo, depth int, ctx *genContext) string {
+func randomTypedExprDepth(t CType, er *exprRand, opts Options, env envInfo, scope scopeInfo, depth int, ctx *genContext) cast.Expr {
 	_ = depth
 	return randomLeafExpr(t, er, opts, env, scope, depth, ctx)
 }
 
-func randomTypedExprDepthFlags(t CType, er *exprRand, opts Options, env envInfo, scope scopeInfo, depth int, ctx *genContext, noFunc bool, noConst bool) string {
+func randomTypedExprDepthFlags(t CType, er *exprRand, opts Options, env envInfo, scope scopeInfo, depth int, ctx *genContext, noFunc bool, noConst bool) cast.Expr {
 	_ = depth
 	return randomLeafExprWithMode(t, er, opts, env, scope, depth, ctx, false, noFunc, noConst)
 }
 
-func randomParamExprDepth(t CType, er *exprRand, opts Options, env envInfo, scope scopeInfo, depth int, ctx *genContext) string {
+func randomParamExprDepth(t CType, er *exprRand, opts Options, env envInfo, scope scopeInfo, depth int, ctx *genContext) cast.Expr {
 	_ = depth
 	return randomParamLeafExpr(t, er, opts, env, scope, depth, ctx)
 }
@@ -1111,7 +1271,7 @@ func exprDecisionBudget(opts Options) int {
 	return 16 + (depth * 12)
 }
 
-func randomTypedExpr(t CType, r *rng, opts Options, env envInfo, scope scopeInfo, ctx *genContext) string {
+func randomTypedExpr(t CType, r *rng, opts Options, env envInfo, scope scopeInfo, ctx *genContext) cast.Expr {
 	er := newExprRand(r, exprDecisionBudget(opts))
 	return randomTypedExprDepth(t, er, opts, env, scope, 0, ctx)
 }
@@ -1145,27 +1305,27 @@ func buildScopedCandidates(r *rng, env envInfo, scope scopeInfo, scopePick int,
 	switch scopePick {
 	case 0:
 		for _, g := range mergedGlobals(env, ctx) {
-			out = append(out, exprVarCandidate{expr: g.name, ctype: g.ctype, assignable: !g.isConst})
+			out = append(out, varCandidates(ident(g.name), g.ctype, !g.isConst, ctx)...)
 		}
 	case 1:
 		for _, l := range mergedLocals(scope, ctx) {
 			if l.name == "x" {
 				continue
 			}
-			out = append(out, exprVarCandidate{expr: l.name, ctype: l.ctype, assignable: true})
+			out = append(out, varCandidates(ident(l.name), l.ctype, true, ctx)...)
 		}
 	case 2:
 		for _, p := range scope.params {
-			out = append(out, exprVarCandidate{expr: p.name, ctype: p.ctype, assignable: true})
+			out = append(out, varCandidates(ident(p.name), p.ctype, true, ctx)...)
 		}
 	}
 	if scopePick != 2 {
-		for _, ptr := range env.pointers {
-			out = append(out, exprVarCandidate{expr: "*" + ptr.name, ctype: ptr.targetTy, assignable: !ptr.constTarget})
+		for _, ptr := range mergedPointers(env, ctx) {
+			out = append(out, exprVarCandidate{expr: ptr.derefExpr(), ctype: ptr.targetTy, assignable: ptr.assignable()})
 		}
-		for _, arr := range env.arrays {
+		for _, arr := range mergedArrays(env, ctx) {
 			out = append(out, exprVarCandidate{
-				expr:       fmt.Sprintf("%s[%d]", arr.name, int(r.upto(uint32(arr.len)))),
+				expr:       arr.randomAccess(r.upto),
 				ctype:      arr.ctype,
 				assignable: true,
 			})
@@ -1187,7 +1347,7 @@ func chooseLValue(r *rng, opts Options, target CType, env envInfo, scope scopeIn
 	return lvalueInfo{expr: pick.expr, ctype: pick.ctype}, true
 }
 
-func emitLValueAssignment(b *strings.Builder, r *rng, opts Options, env envInfo, scope scopeInfo, ctx *genContext) bool {
+func emitLValueAssignment(blk *cast.Block, r *rng, opts Options, env envInfo, scope scopeInfo, ctx *genContext) bool {
 	targetType := CType{Name: "uint32_t", Signed: false, Bits: 32}
 	for _, l := range scope.locals {
 		if l.name == "x" {
@@ -1196,24 +1356,24 @@ func emitLValueAssignment(b *strings.Builder, r *rng, opts Options, env envInfo,
 		}
 	}
 
-	lv := lvalueInfo{expr: "x", ctype: targetType}
+	lv := lvalueInfo{expr: ident("x"), ctype: targetType}
 	if len(scope.locals) == 0 {
 		if picked, ok := chooseLValue(r, opts, targetType, env, scope, ctx); ok {
 			lv = picked
 		} else {
 			// Upstream-like fallback: create a new value when selection fails.
 			name := fmt.Sprintf("lv_%d", r.next31()&0xFFFF)
-			writeLine(b, 1, fmt.Sprintf("%s %s = %s;", targetType.Name, name, randomConstantExpr(targetType, r, opts)))
-			lv = lvalueInfo{expr: name, ctype: targetType}
+			addStmt(blk, &cast.VarDecl{Type: typeNode(targetType), Name: name, Init: randomConstantExpr(targetType, r, opts)})
+			lv = lvalueInfo{expr: ident(name), ctype: targetType}
 		}
 	}
 	rhs := randomTypedExpr(lv.ctype, r, opts, env, scope, ctx)
 	if opts.CompoundAssignment && r.upto(2) == 0 {
-		writeLine(b, 1, fmt.Sprintf("%s += %s;", lv.expr, rhs))
+		addStmt(blk, exprStmt(assign(lv.expr, "+=", rhs)))
 	} else {
-		writeLine(b, 1, fmt.Sprintf("%s = %s;", lv.expr, rhs))
+		addStmt(blk, exprStmt(assign(lv.expr, "=", rhs)))
 	}
-	writeLine(b, 1, fmt.Sprintf("x ^= (uint32_t)%s;", lv.expr))
+	addStmt(blk, mixIntoX(lv.expr, lv.ctype, opts))
 	if ctx != nil {
 		c := exprVarCandidate{expr: lv.expr, ctype: lv.ctype, assignable: true}
 		ctx.mustUse = &c
@@ -1221,27 +1381,24 @@ func emitLValueAssignment(b *strings.Builder, r *rng, opts Options, env envInfo,
 	return true
 }
 
-func maybeDeclareOnDemandLocal(b *strings.Builder, r *rng, opts Options, ctx *genContext) {
+func maybeDeclareOnDemandLocal(blk *cast.Block, r *rng, opts Options, ctx *genContext) {
 	// Disabled until parent-scope local placement mirrors upstream block scoping.
-	_ = b
+	_ = blk
 	_ = r
 	_ = opts
 	_ = ctx
 }
 
-func emitCompositeTypes(b *strings.Builder, r *rng, opts Options, pool []CType) compositeInfo {
+func emitCompositeTypes(file *cast.File, r *rng, opts Options, pool []CType) compositeInfo {
 	info := compositeInfo{}
-	writeLine(b, 0, "/* --- Struct/Union Declarations --- */")
-	// Upstream Probabilities defaults (Probabilities.cpp).
-	const (
-		moreStructUnionTypeProb       = 50
-		bitfieldsCreationProb         = 50
-		bitfieldInNormalStructProb    = 10
-		scalarFieldInFullBitfieldProb = 10
-		bitfieldsSignedProb           = 50
-		fieldVolatileProb             = 30
-		fieldConstProb                = 20
-	)
+	addDecl(file, &cast.Comment{Text: "/* --- Struct/Union Declarations --- */"})
+	moreStructUnionTypeProb := opts.prob(probMoreStructUnionType)
+	bitfieldsCreationProb := opts.prob(probBitfieldsCreation)
+	bitfieldInNormalStructProb := opts.prob(probBitfieldInNormalStruct)
+	scalarFieldInFullBitfieldProb := opts.prob(probScalarFieldInFullBitfields)
+	bitfieldsSignedProb := opts.prob(probBitfieldsSigned)
+	fieldVolatileProb := opts.prob(probFieldVolatile)
+	fieldConstProb := opts.prob(probFieldConst)
 	moreTypesProbability := func(existingTypeCount int) bool {
 		// Type::MoreTypesProbability: keep adding while <10 total types,
 		// then 50% chance for each additional aggregate type.
@@ -1253,7 +1410,7 @@ func emitCompositeTypes(b *strings.Builder, r *rng, opts Options, pool []CType)
 	// Upstream Type::GenerateSimpleTypes pushes eChar..eUInt128, i.e. 13
 	// simple types before aggregate generation starts.
 	typeCount := 13
-	fieldQual := func() string {
+	fieldQual := func() cast.Qual {
 		// Mirrors CVQualifiers::random_qualifiers(..., FieldConstProb, FieldVolatileProb):
 		// volatile draw first, then const draw.
 		isVolatile := opts.VolStructUnionFields && r.flipcoin(fieldVolatileProb)
@@ -1261,21 +1418,15 @@ func emitCompositeTypes(b *strings.Builder, r *rng, opts Options, pool []CType)
 		if isConst && isVolatile && !opts.AllowConstVolatile {
 			isConst = false
 		}
-		q := ""
-		if isConst {
-			q += "const "
-		}
-		if isVolatile {
-			q += "volatile "
-		}
-		return q
+		return qualOf(isConst, isVolatile)
 	}
 	bitfieldLength := func(maxLength int, prior []fieldInfo) int {
 		if maxLength < 1 {
 			maxLength = 1
 		}
 		length := int(r.upto(uint32(maxLength)))
-		noZeroLen := len(prior) == 0 || (prior[len(prior)-1].bitfield && prior[len(prior)-1].bitWidth == 0)
+		// CompCert does not accept zero-width bit-fields.
+		noZeroLen := len(prior) == 0 || (prior[len(prior)-1].bitfield && prior[len(prior)-1].bitWidth == 0) || opts.CComp
 		if length == 0 && noZeroLen {
 			if maxLength <= 2 {
 				length = 1
@@ -1286,110 +1437,110 @@ func emitCompositeTypes(b *strings.Builder, r *rng, opts Options, pool []CType)
 		return length
 	}
 
-	if opts.Structs {
-		sidx := 0
-		maxStructs := min(max(opts.MaxStructFields, 1), 32)
-		for sidx < maxStructs && moreTypesProbability(typeCount) {
-			fieldCount := 1 + int(r.upto(uint32(max(1, opts.MaxStructFields))))
-			st := structTypeInfo{fields: make([]fieldInfo, 0, fieldCount)}
-			writeLine(b, 0, fmt.Sprintf("struct S%d {", sidx))
-			fullBitfields := opts.Bitfields && r.flipcoin(bitfieldsCreationProb)
-			for f := 0; f < fieldCount; f++ {
-				if fullBitfields {
-					if r.flipcoin(scalarFieldInFullBitfieldProb) {
-						name := fmt.Sprintf("f%d", f)
-						t := pickType(r, pool)
-						writeLine(b, 1, fmt.Sprintf("%s%s %s;", fieldQual(), t.Name, name))
-						st.fields = append(st.fields, fieldInfo{name: name, ctype: t})
-						continue
-					}
-					name := fmt.Sprintf("f%d", f)
-					base := "unsigned"
-					if r.flipcoin(bitfieldsSignedProb) {
-						base = "signed"
-					}
-					qual := fieldQual()
-					width := bitfieldLength(opts.IntSize*8, st.fields)
-					writeLine(b, 1, fmt.Sprintf("%s%s %s : %d;", qual, base, name, width))
-					st.fields = append(st.fields, fieldInfo{
-						name: name, ctype: CType{Name: "uint32_t", Bits: 32}, bitfield: true, bitWidth: width,
-					})
-					continue
-				}
-				if opts.Bitfields && r.flipcoin(bitfieldInNormalStructProb) {
-					name := fmt.Sprintf("f%d", f)
-					base := "unsigned"
-					if r.flipcoin(bitfieldsSignedProb) {
-						base = "signed"
-					}
-					qual := fieldQual()
-					width := bitfieldLength(opts.IntSize*8, st.fields)
-					writeLine(b, 1, fmt.Sprintf("%s%s %s : %d;", qual, base, name, width))
-					st.fields = append(st.fields, fieldInfo{
-						name: name, ctype: CType{Name: "uint32_t", Bits: 32}, bitfield: true, bitWidth: width,
-					})
-					continue
+	maxLevel := max(opts.MaxNestedStructLevel, 1)
+	// fieldType picks a member type among the simple types and the aggregates
+	// declared so far whose nesting stays within MaxNestedStructLevel.
+	fieldType := func() CType {
+		return pickAnyTypeWhere(r, opts, pool, info, func(t CType) bool {
+			return info.level(t) < maxLevel
+		})
+	}
+	makeStruct := func(sidx int) {
+		fieldCount := 1 + int(r.upto(uint32(max(1, opts.MaxStructFields))))
+		st := structTypeInfo{fields: make([]fieldInfo, 0, fieldCount)}
+		decl := &cast.StructDecl{Kind: "struct", Name: fmt.Sprintf("S%d", sidx)}
+		fullBitfields := opts.Bitfields && r.flipcoin(bitfieldsCreationProb)
+		for f := 0; f < fieldCount; f++ {
+			name := fmt.Sprintf("f%d", f)
+			if (fullBitfields && !r.flipcoin(scalarFieldInFullBitfieldProb)) ||
+				(!fullBitfields && opts.Bitfields && r.flipcoin(bitfieldInNormalStructProb)) {
+				base := "unsigned"
+				if r.flipcoin(bitfieldsSignedProb) {
+					base = "signed"
 				}
-				name := fmt.Sprintf("f%d", f)
-				t := pickType(r, pool)
-				writeLine(b, 1, fmt.Sprintf("%s%s %s;", fieldQual(), t.Name, name))
-				st.fields = append(st.fields, fieldInfo{name: name, ctype: t})
-			}
-			if opts.PackedStruct {
-				// Type::make_random_struct_type consumes rnd_flipcoin(50) when
-				// packed-struct is enabled (default upstream behavior).
-				_ = r.flipcoin(50)
+				qual := fieldQual()
+				width := bitfieldLength(opts.IntSize*8, st.fields)
+				decl.Fields = append(decl.Fields, bitField(base, qual, name, width))
+				st.fields = append(st.fields, fieldInfo{
+					name: name, ctype: CType{Name: "uint32_t", Bits: 32}, qual: qual, bitfield: true, bitWidth: width,
+				})
+				continue
 			}
-			writeLine(b, 0, "};")
-			writeLine(b, 0, "")
-			info.structs = append(info.structs, st)
-			sidx++
-			typeCount++
-		}
-	}
-
-	if opts.Unions {
-		uidx := 0
-		maxUnions := min(max(opts.MaxUnionFields, 1), 32)
-		for uidx < maxUnions && moreTypesProbability(typeCount) {
-			fieldCount := 1 + int(r.upto(uint32(max(1, opts.MaxUnionFields))))
-			ut := unionTypeInfo{fields: make([]fieldInfo, 0, fieldCount)}
-			writeLine(b, 0, fmt.Sprintf("union U%d {", uidx))
-			for f := 0; f < fieldCount; f++ {
-				name := fmt.Sprintf("f%d", f)
-				if opts.Bitfields && r.flipcoin(bitfieldInNormalStructProb) {
-					base := "unsigned"
-					if r.flipcoin(bitfieldsSignedProb) {
-						base = "signed"
-					}
-					qual := fieldQual()
-					width := bitfieldLength(opts.IntSize*8, ut.fields)
-					writeLine(b, 1, fmt.Sprintf("%s%s %s : %d;", qual, base, name, width))
-					ut.fields = append(ut.fields, fieldInfo{
-						name: name, ctype: CType{Name: "uint32_t", Bits: 32}, bitfield: true, bitWidth: width,
-					})
-					continue
+			t := fieldType()
+			qual := fieldQual()
+			decl.Fields = append(decl.Fields, scalarField(t, qual, name))
+			st.fields = append(st.fields, fieldInfo{name: name, ctype: t, qual: qual})
+			st.level = max(st.level, info.level(t))
+		}
+		st.level++
+		if opts.PackedStruct {
+			// Type::make_random_struct_type consumes rnd_flipcoin(50) when
+			// packed-struct is enabled (default upstream behavior).
+			_ = r.flipcoin(50)
+		}
+		decl.Attrs = drawTypeAttributes(r, opts)
+		st.packed = hasAttribute(decl.Attrs, "packed")
+		addDecl(file, decl, &cast.Blank{})
+		info.structs = append(info.structs, st)
+	}
+	makeUnion := func(uidx int) {
+		fieldCount := 1 + int(r.upto(uint32(max(1, opts.MaxUnionFields))))
+		ut := unionTypeInfo{fields: make([]fieldInfo, 0, fieldCount)}
+		decl := &cast.StructDecl{Kind: "union", Name: fmt.Sprintf("U%d", uidx)}
+		for f := 0; f < fieldCount; f++ {
+			name := fmt.Sprintf("f%d", f)
+			if opts.Bitfields && r.flipcoin(bitfieldInNormalStructProb) {
+				base := "unsigned"
+				if r.flipcoin(bitfieldsSignedProb) {
+					base = "signed"
 				}
-				t := pickType(r, pool)
-				writeLine(b, 1, fmt.Sprintf("%s%s %s;", fieldQual(), t.Name, name))
-				ut.fields = append(ut.fields, fieldInfo{name: name, ctype: t})
+				qual := fieldQual()
+				width := bitfieldLength(opts.IntSize*8, ut.fields)
+				decl.Fields = append(decl.Fields, bitField(base, qual, name, width))
+				ut.fields = append(ut.fields, fieldInfo{
+					name: name, ctype: CType{Name: "uint32_t", Bits: 32}, qual: qual, bitfield: true, bitWidth: width,
+				})
+				continue
 			}
-			writeLine(b, 0, "};")
-			writeLine(b, 0, "")
-			info.unions = append(info.unions, ut)
-			uidx++
-			typeCount++
+			t := fieldType()
+			qual := fieldQual()
+			decl.Fields = append(decl.Fields, scalarField(t, qual, name))
+			ut.fields = append(ut.fields, fieldInfo{name: name, ctype: t, qual: qual})
+			ut.level = max(ut.level, info.level(t))
+		}
+		ut.level++
+		decl.Attrs = drawTypeAttributes(r, opts)
+		ut.packed = hasAttribute(decl.Attrs, "packed")
+		addDecl(file, decl, &cast.Blank{})
+		info.unions = append(info.unions, ut)
+	}
+
+	// Structs and unions are generated interleaved, as upstream
+	// Type::GenerateAllTypes does, so either kind can nest in the other.
+	maxStructs := min(max(opts.MaxStructFields, 1), 32)
+	maxUnions := min(max(opts.MaxUnionFields, 1), 32)
+	for {
+		canStruct := opts.Structs && len(info.structs) < maxStructs
+		canUnion := opts.Unions && len(info.unions) < maxUnions
+		if !canStruct && !canUnion || !moreTypesProbability(typeCount) {
+			break
 		}
+		if canUnion && (!canStruct || r.flipcoin(50)) {
+			makeUnion(len(info.unions))
+		} else {
+			makeStruct(len(info.structs))
+		}
+		typeCount++
 	}
-	writeLine(b, 0, "")
+	addDecl(file, &cast.Blank{})
 
 	return info
 }
 
-func emitGlobals(b *strings.Builder, r *rng, opts Options, info compositeInfo, pool []CType) envInfo {
+func emitGlobals(f *cast.File, r *rng, opts Options, info compositeInfo, pool []CType) envInfo {
 	env := envInfo{}
 	nextGlobalID := 0
-	writeLine(b, 0, "/* --- GLOBAL VARIABLES --- */")
+	addDecl(f, &cast.Comment{Text: "/* --- GLOBAL VARIABLES --- */"})
 	if opts.GlobalVariables {
 		globalCap := max(opts.MaxGlobals, 2)
 		env.globals = make([]globalInfo, 0, globalCap)
@@ -1408,11 +1559,11 @@ func emitGlobals(b *strings.Builder, r *rng, opts Options, info compositeInfo, p
 			// Upstream regular qualifiers (Probabilities.cpp defaults).
 			isConst := false
 			if opts.Consts {
-				isConst = r.upto(100) < 10
+				isConst = r.upto(100) < opts.prob(probRegularConst)
 			}
 			isVolatile := false
 			if opts.Volatiles {
-				isVolatile = r.upto(100) < 50
+				isVolatile = r.upto(100) < opts.prob(probRegularVolatile)
 			}
 			// Avoid degenerate const+volatile saturation.
 			if isConst && isVolatile && r.upto(2) == 0 {
@@ -1420,19 +1571,12 @@ func emitGlobals(b *strings.Builder, r *rng, opts Options, info compositeInfo, p
 			}
 			g := globalInfo{
 				name:       newGlobalName(),
-				ctype:      pickType(r, pool),
+				ctype:      pickType(r, opts, pool),
 				isConst:    isConst,
 				isVolatile: isVolatile,
 			}
-			lit := castLiteral(g.ctype, fmt.Sprintf("0x%08Xu", r.next31()))
-			qual := ""
-			if g.isConst {
-				qual += "const "
-			}
-			if g.isVolatile {
-				qual += "volatile "
-			}
-			writeLine(b, 0, fmt.Sprintf("static %s%s %s = %s;", qual, g.ctype.Name, g.name, lit))
+			init := castExpr(g.ctype, lit("0x%08Xu", r.next31()))
+			addDecl(f, &cast.VarDecl{Storage: "static", Type: qualTypeNode(g.ctype, g.isConst, g.isVolatile), Name: g.name, Init: init})
 			env.globals = append(env.globals, g)
 		}
 
@@ -1443,10 +1587,15 @@ func emitGlobals(b *strings.Builder, r *rng, opts Options, info compositeInfo, p
 				arrLen := 2 + int(r.upto(uint32(max(2, min(opts.MaxArrayLenPerDim, 10)))))
 				ai := arrayInfo{
 					name:  newGlobalName(),
-					ctype: pickType(r, pool),
-					len:   arrLen,
+					ctype: pickType(r, opts, pool),
+					dims:  []int{arrLen},
 				}
-				writeLine(b, 0, fmt.Sprintf("static %s %s[%d] = {0};", ai.ctype.Name, ai.name, arrLen))
+				addDecl(f, &cast.VarDecl{
+					Storage: "static",
+					Type:    ai.declType(),
+					Name:    ai.name,
+					Init:    &cast.InitList{Elems: []cast.Expr{lit("0")}},
+				})
 				env.arrays = append(env.arrays, ai)
 			}
 		}
@@ -1460,25 +1609,20 @@ func emitGlobals(b *strings.Builder, r *rng, opts Options, info compositeInfo, p
 			for i := 0; i < ptrTarget; i++ {
 				target := env.globals[start+int(r.upto(uint32(max(1, len(env.globals)-start))))]
 				p := pointerInfo{
-					name:            newGlobalName(),
-					target:          target.name,
-					targetTy:        target.ctype,
-					volatilePointer: opts.VolatilePointers && r.upto(3) == 0,
-					volatileTarget:  opts.VolatilePointers && r.upto(4) == 0,
-					constTarget:     opts.ConstPointers && r.upto(3) == 0,
-				}
-				targetQual := ""
-				if p.constTarget {
-					targetQual += "const "
+					name:     newGlobalName(),
+					targetTy: target.ctype,
+					quals: []cast.Qual{
+						qualOf(opts.ConstPointers && r.upto(3) == 0, opts.VolatilePointers && r.upto(4) == 0),
+						qualOf(false, opts.VolatilePointers && r.upto(3) == 0),
+					},
+					owner: -1,
 				}
-				if p.volatileTarget {
-					targetQual += "volatile "
-				}
-				ptrQual := ""
-				if p.volatilePointer {
-					ptrQual = "volatile "
-				}
-				writeLine(b, 0, fmt.Sprintf("static %s%s *%s%s = &%s;", targetQual, target.ctype.Name, ptrQual, p.name, p.target))
+				addDecl(f, &cast.VarDecl{
+					Storage: "static",
+					Type:    p.declType(),
+					Name:    p.name,
+					Init:    addrOf(ident(target.name)),
+				})
 				env.pointers = append(env.pointers, p)
 			}
 
@@ -1488,7 +1632,7 @@ func emitGlobals(b *strings.Builder, r *rng, opts Options, info compositeInfo, p
 			for i := 0; i < chainCount; i++ {
 				chainBases := make([]pointerInfo, 0, len(env.pointers))
 				for _, pb := range env.pointers {
-					if pb.constTarget || pb.volatileTarget || pb.volatilePointer {
+					if pb.quals[0] != 0 || pb.quals[1] != 0 {
 						continue
 					}
 					chainBases = append(chainBases, pb)
@@ -1500,11 +1644,11 @@ func emitGlobals(b *strings.Builder, r *rng, opts Options, info compositeInfo, p
 				depth := 2 + int(r.upto(uint32(max(1, min(opts.MaxPointerDepth, 4)-1))))
 
 				prevName := base.name
-				baseType := base.targetTy.Name
+				var ptrType cast.Type = &cast.Pointer{Elem: typeNode(base.targetTy)}
 				for d := 2; d <= depth; d++ {
 					name := newGlobalName()
-					stars := strings.Repeat("*", d)
-					writeLine(b, 0, fmt.Sprintf("static %s %s%s = &%s;", baseType, stars, name, prevName))
+					ptrType = &cast.Pointer{Elem: ptrType}
+					addDecl(f, &cast.VarDecl{Storage: "static", Type: ptrType, Name: name, Init: addrOf(ident(prevName))})
 					prevName = name
 					env.chains = append(env.chains, name)
 				}
@@ -1513,84 +1657,84 @@ func emitGlobals(b *strings.Builder, r *rng, opts Options, info compositeInfo, p
 	}
 
 	for i := range info.structs {
-		writeLine(b, 0, fmt.Sprintf("static struct S%d gs_%d;", i, i))
+		addDecl(f, &cast.VarDecl{Storage: "static", Type: &cast.Named{Name: fmt.Sprintf("struct S%d", i)}, Name: fmt.Sprintf("gs_%d", i)})
 	}
 	for i := range info.unions {
-		writeLine(b, 0, fmt.Sprintf("static union U%d gu_%d;", i, i))
+		addDecl(f, &cast.VarDecl{Storage: "static", Type: &cast.Named{Name: fmt.Sprintf("union U%d", i)}, Name: fmt.Sprintf("gu_%d", i)})
 	}
 	env.nextID = nextGlobalID
-	writeLine(b, 0, "")
+	addDecl(f, &cast.Blank{})
 	return env
 }
 
-func emitFuncDecls(b *strings.Builder, funcs []funcInfo) {
-	writeLine(b, 0, "/* --- FORWARD DECLARATIONS --- */")
+func emitFuncDecls(f *cast.File, funcs []funcInfo) {
+	addDecl(f, &cast.Comment{Text: "/* --- FORWARD DECLARATIONS --- */"})
 	for _, fn := range funcs {
-		params := "void"
-		if len(fn.params) > 0 {
-			pp := make([]string, 0, len(fn.params))
-			for _, p := range fn.params {
-				pp = append(pp, fmt.Sprintf("%s %s", p.ctype.Name, p.name))
-			}
-			params = strings.Join(pp, ", ")
-		}
-		writeLine(b, 0, fmt.Sprintf("static %s %s(%s);", fn.ret.Name, fn.name, params))
+		decl := funcDecl(fn)
+		addDecl(f, &decl)
 	}
-	writeLine(b, 0, "")
+	addDecl(f, &cast.Blank{})
 }
 
-func emitArithmeticMutation(b *strings.Builder, r *rng, opts Options) {
+func emitArithmeticMutation(blk *cast.Block, r *rng, opts Options) {
+	x := ident("x")
+	u32 := CType{Name: "uint32_t", Signed: false, Bits: 32}
 	if opts.Muls && r.upto(4) == 0 {
-		writeLine(b, 1, fmt.Sprintf("x = x * (0x%08Xu | 1u);", r.next31()))
+		addStmt(blk, exprStmt(assign(x, "=", safeArithExpr("*", u32, x, paren(binary(lit("0x%08Xu", r.next31()), "|", lit("1u"))), opts))))
 		return
 	}
 	if opts.Divs && r.upto(3) == 0 {
-		writeLine(b, 1, fmt.Sprintf("x = %s;", safeDivU32Expr("x", "((x & 255u) + 1u)", opts)))
+		divisor := paren(binary(paren(binary(x, "&", lit("255u"))), "+", lit("1u")))
+		addStmt(blk, exprStmt(assign(x, "=", safeArithExpr("/", u32, x, divisor, opts))))
 		return
 	}
 	if opts.UnaryPlusOperator && r.upto(3) == 0 {
-		writeLine(b, 1, fmt.Sprintf("x = (+x) ^ 0x%08Xu;", r.next31()))
+		addStmt(blk, exprStmt(assign(x, "=", binary(paren(&cast.Unary{Op: "+", X: x}), "^", lit("0x%08Xu", r.next31())))))
 		return
 	}
-	writeLine(
-		b,
-		1,
-		fmt.Sprintf("x = (%s) ^ (%s) ^ 0x%08Xu;", safeLShiftU32Expr("x", "1", opts), safeRShiftU32Expr("x", "1", opts), r.next31()),
+	// The shift count is a plain int literal.
+	count := CType{Name: "int32_t", Signed: true, Bits: 32}
+	mixed := binary(
+		binary(paren(safeShiftExpr("<<", u32, x, count, lit("1"), opts)), "^", paren(safeShiftExpr(">>", u32, x, count, lit("1"), opts))),
+		"^",
+		lit("0x%08Xu", r.next31()),
 	)
+	addStmt(blk, exprStmt(assign(x, "=", mixed)))
 }
 
-func emitIncDecMutation(b *strings.Builder, r *rng, opts Options) bool {
+func emitIncDecMutation(blk *cast.Block, r *rng, opts Options) bool {
 	if !(opts.PreIncrOperator || opts.PreDecrOperator || opts.PostIncrOperator || opts.PostDecrOperator) {
 		return false
 	}
+	x := ident("x")
 	switch r.upto(4) {
 	case 0:
 		if opts.PreIncrOperator {
-			writeLine(b, 1, "++x;")
+			addStmt(blk, exprStmt(&cast.Unary{Op: "++", X: x}))
 			return true
 		}
 	case 1:
 		if opts.PreDecrOperator {
-			writeLine(b, 1, "--x;")
+			addStmt(blk, exprStmt(&cast.Unary{Op: "--", X: x}))
 			return true
 		}
 	case 2:
 		if opts.PostIncrOperator {
-			writeLine(b, 1, "x++;")
+			addStmt(blk, exprStmt(&cast.Postfix{X: x, Op: "++"}))
 			return true
 		}
 	case 3:
 		if opts.PostDecrOperator {
-			writeLine(b, 1, "x--;")
+			addStmt(blk, exprStmt(&cast.Postfix{X: x, Op: "--"}))
 			return true
 		}
 	}
 	return false
 }
 
-func emitGlobalMutation(b *strings.Builder, r *rng, opts Options, env envInfo, scope scopeInfo, ctx *genContext) {
+func emitGlobalMutation(blk *cast.Block, r *rng, opts Options, env envInfo, scope scopeInfo, ctx *genContext) {
 	if len(env.globals) == 0 {
-		emitArithmeticMutation(b, r, opts)
+		emitArithmeticMutation(blk, r, opts)
 		return
 	}
 	writable := make([]globalInfo, 0, len(env.globals))
@@ -1601,83 +1745,55 @@ func emitGlobalMutation(b *strings.Builder, r *rng, opts Options, env envInfo, s
 		writable = append(writable, g)
 	}
 	if len(writable) == 0 {
-		emitArithmeticMutation(b, r, opts)
+		emitArithmeticMutation(blk, r, opts)
 		return
 	}
 	g := writable[int(r.upto(uint32(len(writable))))]
 	rhs := randomTypedExpr(g.ctype, r, opts, env, scope, ctx)
 	if opts.CompoundAssignment {
-		writeLine(b, 1, fmt.Sprintf("%s += %s;", g.name, rhs))
+		addStmt(blk, exprStmt(assign(ident(g.name), "+=", rhs)))
 	} else {
-		writeLine(b, 1, fmt.Sprintf("%s = %s;", g.name, safeAddExpr(g.ctype, g.name, rhs, opts)))
-	}
-	if ctx != nil {
-		c := exprVarCandidate{expr: g.name, ctype: g.ctype, assignable: !g.isConst}
-		ctx.mustUse = &c
-	}
-}
-
-func emitArrayMutation(b *strings.Builder, r *rng, opts Options, env envInfo, scope scopeInfo, ctx *genContext) {
-	if !opts.Arrays || len(env.arrays) == 0 {
-		emitArithmeticMutation(b, r, opts)
-		return
-	}
-	ai := env.arrays[int(r.upto(uint32(len(env.arrays))))]
-	idxMask := max(1, min(opts.MaxArrayLenPerDim, 8)-1)
-	rhs := randomTypedExpr(ai.ctype, r, opts, env, scope, ctx)
-	writeLine(b, 1, fmt.Sprintf("%s[x & %du] ^= %s;", ai.name, idxMask, rhs))
-	if opts.EmbeddedAssigns {
-		one := castLiteral(ai.ctype, "1u")
-		writeLine(
-			b,
-			1,
-			fmt.Sprintf(
-				"x = (%s[x & %du] = %s);",
-				ai.name,
-				idxMask,
-				safeAddExpr(ai.ctype, fmt.Sprintf("%s[x & %du]", ai.name, idxMask), one, opts),
-			),
-		)
+		addStmt(blk, exprStmt(assign(ident(g.name), "=", safeAddExpr(g.ctype, ident(g.name), rhs, opts))))
 	}
 	if ctx != nil {
-		c := exprVarCandidate{expr: fmt.Sprintf("%s[x & %du]", ai.name, idxMask), ctype: ai.ctype, assignable: true}
+		c := exprVarCandidate{expr: ident(g.name), ctype: g.ctype, assignable: !g.isConst}
 		ctx.mustUse = &c
 	}
 }
 
-func emitPointerMutation(b *strings.Builder, r *rng, opts Options, env envInfo, scope scopeInfo, ctx *genContext) {
+func emitPointerMutation(blk *cast.Block, r *rng, opts Options, env envInfo, scope scopeInfo, ctx *genContext) {
 	if !opts.Pointers || len(env.pointers) == 0 {
-		emitArithmeticMutation(b, r, opts)
+		emitArithmeticMutation(blk, r, opts)
 		return
 	}
 	pi := env.pointers[int(r.upto(uint32(len(env.pointers))))]
 	rhs := randomTypedExpr(pi.targetTy, r, opts, env, scope, ctx)
 	if opts.CompoundAssignment {
-		writeLine(b, 1, fmt.Sprintf("*%s ^= %s;", pi.name, rhs))
+		addStmt(blk, exprStmt(assign(pi.derefExpr(), "^=", rhs)))
 	} else {
-		writeLine(b, 1, fmt.Sprintf("*%s = *%s ^ %s;", pi.name, pi.name, rhs))
+		addStmt(blk, exprStmt(assign(pi.derefExpr(), "=", binary(pi.derefExpr(), "^", rhs))))
 	}
 	if ctx != nil {
-		c := exprVarCandidate{expr: "*" + pi.name, ctype: pi.targetTy, assignable: !pi.constTarget}
+		c := exprVarCandidate{expr: pi.derefExpr(), ctype: pi.targetTy, assignable: pi.assignable()}
 		ctx.mustUse = &c
 	}
 }
 
-func emitLocalMutation(b *strings.Builder, r *rng, opts Options, env envInfo, scope scopeInfo, ctx *genContext) {
+func emitLocalMutation(blk *cast.Block, r *rng, opts Options, env envInfo, scope scopeInfo, ctx *genContext) {
 	if len(scope.locals) == 0 {
-		emitArithmeticMutation(b, r, opts)
+		emitArithmeticMutation(blk, r, opts)
 		return
 	}
 	li := scope.locals[int(r.upto(uint32(len(scope.locals))))]
 	rhs := randomTypedExpr(li.ctype, r, opts, env, scope, ctx)
 	if opts.CompoundAssignment {
-		writeLine(b, 1, fmt.Sprintf("%s += %s;", li.name, rhs))
+		addStmt(blk, exprStmt(assign(ident(li.name), "+=", rhs)))
 	} else {
-		writeLine(b, 1, fmt.Sprintf("%s = %s;", li.name, safeAddExpr(li.ctype, li.name, rhs, opts)))
+		addStmt(blk, exprStmt(assign(ident(li.name), "=", safeAddExpr(li.ctype, ident(li.name), rhs, opts))))
 	}
-	writeLine(b, 1, fmt.Sprintf("x ^= (uint32_t)%s;", li.name))
+	addStmt(blk, mixIntoX(ident(li.name), li.ctype, opts))
 	if ctx != nil {
-		c := exprVarCandidate{expr: li.name, ctype: li.ctype, assignable: true}
+		c := exprVarCandidate{expr: ident(li.name), ctype: li.ctype, assignable: true}
 		ctx.mustUse = &c
 	}
 }
@@ -1703,21 +1819,22 @@ func (s *functionFlowState) appendNewFunction(r *rng, forceRet *CType) (funcInfo
 		for p := 0; p < pcount; p++ {
 			fn.params = append(fn.params, paramInfo{
 				name:  s.allocParamName(),
-				ctype: pickType(r, s.pool),
+				ctype: pickAnyType(r, s.opts, s.pool, s.info, s.opts.ArgStructs, s.opts.ArgUnions),
 			})
 		}
+		s.drawInlining(r, &fn)
 	} else {
 		fn = s.makeFuncSignature(r, s.nextIdx)
 	}
 	s.nextIdx++
 	s.funcs = append(s.funcs, fn)
 	s.built = append(s.built, false)
-	s.defs = append(s.defs, "")
+	s.defs = append(s.defs, nil)
 	return fn, len(s.funcs) - 1, true
 }
 
 func emitFunctionCallMutation(
-	b *strings.Builder,
+	blk *cast.Block,
 	r *rng,
 	opts Options,
 	env envInfo,
@@ -1758,25 +1875,23 @@ func emitFunctionCallMutation(
 			return false
 		}
 	}
-	args := "void"
+	args := make([]cast.Expr, 0, len(callee.params))
 	if len(callee.params) > 0 {
-		argExprs := make([]string, 0, len(callee.params))
 		er := newExprRand(r, exprDecisionBudget(opts))
 		for _, p := range callee.params {
-			argExprs = append(argExprs, randomParamExprDepth(p.ctype, er, opts, env, scope, 0, ctx))
+			args = append(args, randomParamExprDepth(p.ctype, er, opts, env, scope, 0, ctx))
 		}
-		args = strings.Join(argExprs, ", ")
 	}
-	if args == "void" {
-		writeLine(b, 1, fmt.Sprintf("x ^= (uint32_t)%s();", callee.name))
-	} else {
-		writeLine(b, 1, fmt.Sprintf("x ^= (uint32_t)%s(%s);", callee.name, args))
+	if callee.ret.Aggregate {
+		mixAggregateIntoX(blk, call(callee.name, args...), callee.ret, state.info, opts)
+		return true
 	}
+	addStmt(blk, mixIntoX(call(callee.name, args...), callee.ret, opts))
 	return true
 }
 
 func emitStatement(
-	b *strings.Builder,
+	blk *cast.Block,
 	r *rng,
 	opts Options,
 	env envInfo,
@@ -1793,97 +1908,110 @@ func emitStatement(
 	if stmtBudget != nil && *stmtBudget == 0 {
 		return true
 	}
-	maybeDeclareOnDemandLocal(b, r, opts, ctx)
+	maybeDeclareOnDemandLocal(blk, r, opts, ctx)
 	if stmtBudget != nil && *stmtBudget > 0 {
 		*stmtBudget = *stmtBudget - 1
 	}
 	chooseStmt := func() stmtKind {
+		weights, total := opts.statementWeights()
 		toKind := func(v int) stmtKind {
-			switch {
-			case v < 15:
-				return stmtIfElse
-			case v < 30:
-				return stmtFor
-			case v < 35:
-				return stmtReturn
-			case v < 40:
-				return stmtContinue
-			case v < 45:
-				return stmtBreak
-			case opts.Jumps && opts.Arrays && v < 50:
-				return stmtGoto
-			case opts.Jumps && opts.Arrays && v < 60:
-				return stmtArrayOp
-			case opts.Jumps && !opts.Arrays && v < 50:
-				return stmtGoto
-			case !opts.Jumps && opts.Arrays && v < 55:
-				return stmtArrayOp
-			default:
-				return stmtAssign
+			for _, w := range weights {
+				if v < w.weight {
+					return w.kind
+				}
+				v -= w.weight
 			}
+			return stmtAssign
+		}
+		filtered := func(k stmtKind) bool {
+			if (k == stmtBreak || k == stmtContinue) && !inLoop {
+				return true
+			}
+			return depth >= max(1, opts.MaxBlockDepth) && (k == stmtIfElse || k == stmtFor || k == stmtBlock)
 		}
 		// Mimics upstream rnd_upto(..., StatementFilter):
 		// retries happen inside one RNG API call.
 		if dec.r != nil {
-			v := int(dec.r.uptoWithFilter(100, func(x uint32) bool {
-				k := toKind(int(x))
-				if (k == stmtBreak || k == stmtContinue) && !inLoop {
-					return true
-				}
-				if depth >= max(1, opts.MaxBlockDepth) && (k == stmtIfElse || k == stmtFor) {
-					return true
+			allowed := false
+			for _, w := range weights {
+				if w.weight > 0 && !filtered(w.kind) {
+					allowed = true
+					break
 				}
-				return false
+			}
+			if !allowed {
+				// A configured statement group can leave nothing eligible
+				// here; the draw is still made, so the stream stays in step.
+				dec.r.upto(uint32(max(1, total)))
+				return stmtAssign
+			}
+			v := int(dec.r.uptoWithFilter(uint32(total), func(x uint32) bool {
+				return filtered(toKind(int(x)))
 			}))
 			return toKind(v)
 		}
-		return toKind(int(dec.pick(0, 100)))
+		return toKind(int(dec.pick(0, uint32(total))))
 	}
 
-	switch chooseStmt() {
+	kind := chooseStmt()
+	if r.dfs != nil && !opts.partialExpandAllows(kind) {
+		r.dfs.suspend()
+		defer r.dfs.resume()
+	}
+	switch kind {
 	case stmtAssign:
-		if !emitLValueAssignment(b, r, opts, env, scope, ctx) {
+		if !emitLValueAssignment(blk, r, opts, env, scope, ctx) {
 			return false
 		}
 	case stmtIfElse:
-		cond := fmt.Sprintf("((x & %du) != 0u)", 1+dec.pick(1, 7))
-		if opts.ConstAsCondition && dec.pick(2, 5) == 0 {
-			cond = fmt.Sprintf("(%s != 0u)", randomConstantExpr(CType{Name: "uint32_t", Signed: false, Bits: 32}, r, opts))
-		} else if dec.pick(3, 3) == 0 {
-			er := newExprRand(r, exprDecisionBudget(opts))
-			noConst := !opts.ConstAsCondition
-			e := randomTypedExprDepthFlags(CType{Name: "uint32_t", Signed: false, Bits: 32}, er, opts, env, scope, 0, ctx, false, noConst)
-			cond = fmt.Sprintf("((uint32_t)%s != 0u)", e)
-		}
-		writeLine(b, 1, fmt.Sprintf("if %s {", cond))
-		emitStatements(b, r, opts, env, scope, state, info, from, depth+1, false, stmtBudget, ctx)
-		writeLine(b, 1, "} else {")
-		emitStatements(b, r, opts, env, scope, state, info, from, depth+1, false, stmtBudget, ctx)
-		writeLine(b, 1, "}")
+		cond := branchCondition(r, opts, env, scope, ctx, dec)
+		then, els := &cast.Block{}, &cast.Block{}
+		emitStatements(then, r, opts, env, scope, state, info, from, depth+1, false, stmtBudget, ctx)
+		emitStatements(els, r, opts, env, scope, state, info, from, depth+1, false, stmtBudget, ctx)
+		addStmt(blk, &cast.If{Cond: cond, Then: then, Else: els})
 	case stmtFor:
 		bound := 1 + int(dec.pick(3, 5))
-		writeLine(b, 1, fmt.Sprintf("for (uint32_t i = 0; i < %du; ++i) {", bound))
-		writeLine(b, 2, fmt.Sprintf("x += (i ^ 0x%08Xu);", dec.vals[4]))
-		emitStatements(b, r, opts, env, scope, state, info, from, depth+1, true, stmtBudget, ctx)
-		writeLine(b, 1, "}")
+		i := ident("i")
+		loop := &cast.For{
+			Init:     &cast.VarDecl{Type: &cast.Named{Name: "uint32_t"}, Name: "i", Init: lit("0")},
+			Cond:     binary(i, "<", lit("%du", bound)),
+			Post:     &cast.Unary{Op: "++", X: i},
+			Prologue: exprStmt(assign(ident("x"), "+=", paren(binary(i, "^", lit("0x%08Xu", dec.vals[4]))))),
+		}
+		body := &cast.Block{}
+		emitStatements(body, r, opts, env, scope, state, info, from, depth+1, true, stmtBudget, ctx)
+		loop.Body = body
+		addStmt(blk, loop)
 	case stmtReturn:
 		ret := scope.returnVar
 		if ret == "" {
 			ret = "l_0"
 		}
-		writeLine(b, 1, fmt.Sprintf("%s ^= (uint32_t)x;", ret))
-		writeLine(b, 1, fmt.Sprintf("return %s;", ret))
+		if lhs, t, ok := returnSlot(ret, scope.returnType, info); ok {
+			if t.Float {
+				addStmt(blk, exprStmt(assign(lhs, "=", castExpr(t, ident("x")))))
+			} else {
+				addStmt(blk, exprStmt(assign(lhs, "^=", &cast.Cast{Type: &cast.Named{Name: "uint32_t"}, X: ident("x")})))
+			}
+		}
+		addStmt(blk, &cast.Return{X: ident(ret)})
+	case stmtBlock:
+		inner := &cast.Block{}
+		emitStatements(inner, r, opts, env, scope, state, info, from, depth+1, inLoop, stmtBudget, ctx)
+		addStmt(blk, inner)
+	case stmtInvoke:
+		return emitFunctionCallMutation(blk, r, opts, env, scope, state, from, ctx)
 	case stmtContinue:
-		writeLine(b, 1, "continue;")
+		addStmt(blk, &cast.Continue{})
 	case stmtBreak:
-		writeLine(b, 1, "break;")
+		addStmt(blk, &cast.Break{})
 	case stmtGoto:
-		return false
+		return emitGoto(blk, r, branchCondition(r, opts, env, scope, ctx, dec), ctx)
 	case stmtArrayOp:
-		if !opts.Arrays || len(env.arrays) == 0 {
+		if !opts.Arrays || len(mergedArrays(env, ctx)) == 0 {
 			return false
 		}
-		emitArrayMutation(b, r, opts, env, scope, ctx)
+		emitArrayMutation(blk, r, opts, env, scope, ctx)
 	default:
 		return false
 	}
@@ -1891,7 +2019,7 @@ func emitStatement(
 }
 
 func emitStatements(
-	b *strings.Builder,
+	blk *cast.Block,
 	r *rng,
 	opts Options,
 	env envInfo,
@@ -1907,6 +2035,10 @@ func emitStatements(
 	if stmtBudget != nil && *stmtBudget == 0 {
 		return
 	}
+	if ctx != nil {
+		ctx.blocks = append(ctx.blocks, blk)
+		defer func() { ctx.blocks = ctx.blocks[:len(ctx.blocks)-1] }()
+	}
 	stmtLimit := max(1, opts.MaxBlockSize)
 	base := 2
 	if depth > 0 {
@@ -1917,6 +2049,7 @@ func emitStatements(
 		if stmtBudget != nil && *stmtBudget == 0 {
 			break
 		}
+		maybeInjectUB(blk, r, opts, env, ctx)
 		const maxStmtAttempts = 8
 		ok := false
 		for attempt := 0; attempt < maxStmtAttempts; attempt++ {
@@ -1927,14 +2060,14 @@ func emitStatements(
 			}
 			snap := takeGenSnapshot(ctx)
 
-			var tmp strings.Builder
-			if emitStatement(&tmp, r, opts, env, scope, state, info, from, depth, inLoop, stmtBudget, ctx, dec) {
-				b.WriteString(tmp.String())
+			mark := len(blk.Stmts)
+			if emitStatement(blk, r, opts, env, scope, state, info, from, depth, inLoop, stmtBudget, ctx, dec) {
 				ok = true
 				break
 			}
 
 			// Reject path: rollback to keep statement-local retries side-effect free.
+			blk.Stmts = blk.Stmts[:mark]
 			if stmtBudget != nil && snapStmtBudget >= 0 {
 				*stmtBudget = snapStmtBudget
 			}
@@ -1942,11 +2075,28 @@ func emitStatements(
 		}
 		if !ok {
 			// Last-resort deterministic no-op-like mutation when all attempts fail.
-			writeLine(b, 1, "x ^= 0u;")
+			addStmt(blk, exprStmt(assign(ident("x"), "^=", lit("0u"))))
 		}
 	}
 }
 
+// branchCondition builds the condition of an if statement or conditional
+// jump: a test of the running checksum, a constant (with
+// --const-as-condition) or a random expression.
+func branchCondition(r *rng, opts Options, env envInfo, scope scopeInfo, ctx *genContext, dec stmtDecision) cast.Expr {
+	u32 := CType{Name: "uint32_t", Signed: false, Bits: 32}
+	cond := binary(paren(binary(ident("x"), "&", lit("%du", 1+dec.pick(1, 7)))), "!=", lit("0u"))
+	if opts.ConstAsCondition && dec.pick(2, 5) == 0 {
+		cond = binary(randomConstantExpr(u32, r, opts), "!=", lit("0u"))
+	} else if dec.pick(3, 3) == 0 {
+		er := newExprRand(r, exprDecisionBudget(opts))
+		noConst := !opts.ConstAsCondition
+		e := randomTypedExprDepthFlags(u32, er, opts, env, scope, 0, ctx, false, noConst)
+		cond = binary(&cast.Cast{Type: typeNode(u32), X: e}, "!=", lit("0u"))
+	}
+	return cond
+}
+
 func emitSingleFuncDef(
 	r *rng,
 	opts Options,
@@ -1957,7 +2107,7 @@ func emitSingleFuncDef(
 	env envInfo,
 	info compositeInfo,
 	stmtBudget *int,
-) string {
+) *cast.FuncDef {
 	return emitSingleFuncDefOnce(r, opts, fn, state, idx, maxBlock, env, info, stmtBudget)
 }
 
@@ -1971,33 +2121,38 @@ func emitSingleFuncDefOnce(
 	env envInfo,
 	info compositeInfo,
 	stmtBudget *int,
-) string {
+) *cast.FuncDef {
 	fdec := nextFuncDecision(r)
-	var b strings.Builder
+	def := &cast.FuncDef{FuncDecl: funcDecl(fn), Body: &cast.Block{}}
+	body := def.Body
 
-	params := "void"
-	if len(fn.params) > 0 {
-		pp := make([]string, 0, len(fn.params))
-		for _, p := range fn.params {
-			pp = append(pp, fmt.Sprintf("%s %s", p.ctype.Name, p.name))
-		}
-		params = strings.Join(pp, ", ")
-	}
-	writeLine(&b, 0, fmt.Sprintf("static %s %s(%s) {", fn.ret.Name, fn.name, params))
 	retName := "l_0"
 	if state != nil {
 		retName = state.allocLocalName()
 	}
-	writeLine(&b, 1, fmt.Sprintf("%s %s = %s;", fn.ret.Name, retName, castLiteral(fn.ret, "0u")))
+	retInit := castExpr(fn.ret, lit("0u"))
+	if fn.ret.Aggregate {
+		retInit = aggregateZeroInit()
+	}
+	addStmt(body, &cast.VarDecl{Type: typeNode(fn.ret), Name: retName, Init: retInit})
+	u32 := CType{Name: "uint32_t", Signed: false, Bits: 32}
+	xInit := lit("0u")
 	if len(env.globals) >= 2 {
-		writeLine(&b, 1, fmt.Sprintf("uint32_t x = ((uint32_t)%s) + ((uint32_t)%s);", env.globals[0].name, env.globals[1].name))
-	} else {
-		writeLine(&b, 1, "uint32_t x = 0u;")
+		xInit = binary(
+			paren(convertExpr(env.globals[0].ctype, u32, ident(env.globals[0].name), opts)),
+			"+",
+			paren(convertExpr(env.globals[1].ctype, u32, ident(env.globals[1].name), opts)),
+		)
 	}
+	addStmt(body, &cast.VarDecl{Type: typeNode(u32), Name: "x", Init: xInit})
 
 	locals := make([]localInfo, 0, 1)
 	locals = append(locals, localInfo{name: "x", ctype: CType{Name: "uint32_t", Signed: false, Bits: 32}})
-	scope := scopeInfo{params: fn.params, locals: locals, returnVar: retName}
+	if fn.ret.Aggregate {
+		// The aggregate return value is a struct-typed local like any other.
+		locals = append(locals, localInfo{name: retName, ctype: fn.ret})
+	}
+	scope := scopeInfo{params: fn.params, locals: locals, returnVar: retName, returnType: fn.ret}
 	ctx := &genContext{
 		state: state,
 		from:  idx,
@@ -2005,9 +2160,13 @@ func emitSingleFuncDefOnce(
 	}
 
 	for _, p := range fn.params {
-		writeLine(&b, 1, fmt.Sprintf("x ^= (uint32_t)%s;", p.name))
+		if p.ctype.Aggregate {
+			mixAggregateIntoX(body, ident(p.name), p.ctype, info, opts)
+			continue
+		}
+		addStmt(body, mixIntoX(ident(p.name), p.ctype, opts))
 	}
-	emitStatements(&b, r, opts, env, scope, state, info, idx, 0, false, stmtBudget, ctx)
+	emitStatements(body, r, opts, env, scope, state, info, idx, 0, false, stmtBudget, ctx)
 	if len(env.globals) > 0 {
 		writable := make([]globalInfo, 0, len(env.globals))
 		for _, g := range env.globals {
@@ -2018,14 +2177,37 @@ func emitSingleFuncDefOnce(
 		}
 		if len(writable) > 0 {
 			g := writable[int(fdec.pick(2, uint32(len(writable))))]
-			writeLine(&b, 1, fmt.Sprintf("%s ^= %s;", g.name, randomTypedExpr(g.ctype, r, opts, env, scope, ctx)))
+			addStmt(body, xorAssign(ident(g.name), g.ctype, randomTypedExpr(g.ctype, r, opts, env, scope, ctx), opts))
+		}
+	}
+	if lhs, t, ok := returnSlot(retName, fn.ret, info); ok {
+		retOp := "^="
+		if t.Float {
+			// Floating-point values cannot be xor-ed; the result is x itself.
+			retOp = "="
 		}
+		addStmt(body, exprStmt(assign(lhs, retOp, castExpr(t, ident("x")))))
+	}
+	addStmt(body, &cast.Return{X: ident(retName)})
+	// The ret local and x come first; labels and pointers to locals are
+	// placed after them.
+	resolveGotos(body, 2, ctx)
+	bindLocalPointers(body, 2, idx, opts, state)
+	return def
+}
+
+// returnSlot returns the scalar lvalue x is folded into before returning ret,
+// a variable of type t: ret itself, or the first writable member of an
+// aggregate return value.
+func returnSlot(ret string, t CType, info compositeInfo) (cast.Expr, CType, bool) {
+	if !t.Aggregate {
+		return ident(ret), t, true
+	}
+	f, ok := info.writableField(t)
+	if !ok {
+		return nil, t, false
 	}
-	writeLine(&b, 1, fmt.Sprintf("%s ^= %s;", retName, castLiteral(fn.ret, "x")))
-	writeLine(&b, 1, fmt.Sprintf("return %s;", retName))
-	writeLine(&b, 0, "}")
-	writeLine(&b, 0, "")
-	return b.String()
+	return memberPath(ident(ret), f.path), f.ctype, true
 }
 
 func (s *functionFlowState) allocParamName() string {
@@ -2047,34 +2229,25 @@ func (s *functionFlowState) makeFuncSignature(r *rng, idx int) funcInfo {
 	// Function::make_first uses RandomReturnType() over AllTypes (simple + aggregates),
 	// while later signatures are chosen from random types as they are created.
 	if idx == 1 {
-		allCount := len(s.pool) + len(s.info.structs) + len(s.info.unions)
-		if allCount <= 0 {
+		if len(s.pool) == 0 {
 			fn.ret = CType{Name: "uint32_t", Signed: false, Bits: 32}
 		} else {
-			pick := int(r.upto(uint32(allCount)))
-			switch {
-			case pick < len(s.pool):
-				fn.ret = s.pool[pick]
-			case pick < len(s.pool)+len(s.info.structs):
-				fn.ret = CType{Name: fmt.Sprintf("struct S%d", pick-len(s.pool)), Bits: 32}
-			default:
-				fn.ret = CType{Name: fmt.Sprintf("union U%d", pick-len(s.pool)-len(s.info.structs)), Bits: 32}
-			}
+			fn.ret = pickAnyType(r, s.opts, s.pool, s.info, s.opts.ReturnStructs, s.opts.ReturnUnions)
 		}
 	} else {
-		fn.ret = pickType(r, s.pool)
+		fn.ret = pickAnyType(r, s.opts, s.pool, s.info, s.opts.ReturnStructs, s.opts.ReturnUnions)
 	}
 	if idx == 1 {
 		// make_first() creates return variable qualifiers via
 		// CVQualifiers::random_qualifiers(type) (no_volatile=true), which still
 		// consumes volatile/const draws on the object itself.
 		if s.opts.Volatiles {
-			_ = r.flipcoin(50)
+			_ = r.flipcoin(s.opts.prob(probRegularVolatile))
 		} else {
 			_ = r.flipcoin(0)
 		}
 		if s.opts.Consts {
-			_ = r.flipcoin(10)
+			_ = r.flipcoin(s.opts.prob(probRegularConst))
 		} else {
 			_ = r.flipcoin(0)
 		}
@@ -2094,18 +2267,41 @@ func (s *functionFlowState) makeFuncSignature(r *rng, idx int) funcInfo {
 	for p := 0; p < pcount; p++ {
 		fn.params = append(fn.params, paramInfo{
 			name:  s.allocParamName(),
-			ctype: pickType(r, s.pool),
+			ctype: pickAnyType(r, s.opts, s.pool, s.info, s.opts.ArgStructs, s.opts.ArgUnions),
 		})
 	}
+	if idx == 1 && s.opts.hasExtension() {
+		// Extensions pass the inputs of the program to func_1.
+		fn.params = s.extensionParams(r)
+	}
+	s.drawInlining(r, &fn)
 	return fn
 }
 
-func emitFunctionsUpstreamFlow(b *strings.Builder, r *rng, opts Options, pool []CType, maxBlock int, env envInfo, info compositeInfo) ([]funcInfo, []globalInfo) {
+// drawInlining marks fn inline with InlineFunctionProb when --inline-function
+// is on. A quarter of the functions also force the inliner's decision:
+// always_inline on inline functions, noinline on the others.
+func (s *functionFlowState) drawInlining(r *rng, fn *funcInfo) {
+	if !s.opts.InlineFunction {
+		return
+	}
+	fn.inline = r.flipcoin(s.opts.prob(probInlineFunction))
+	if r.upto(4) != 0 {
+		return
+	}
+	if fn.inline {
+		fn.attrs = append(fn.attrs, "always_inline")
+	} else {
+		fn.attrs = append(fn.attrs, "noinline")
+	}
+}
+
+func emitFunctionsUpstreamFlow(f *cast.File, r *rng, opts Options, pool []CType, maxBlock int, env envInfo, info compositeInfo) ([]funcInfo, []globalInfo, []arrayInfo) {
 	maxFuncs := max(opts.MaxFuncs, 1)
 	state := &functionFlowState{
 		funcs:        []funcInfo{},
 		built:        []bool{},
-		defs:         []string{},
+		defs:         []*cast.FuncDef{},
 		maxFuncs:     maxFuncs,
 		nextIdx:      2,
 		nextParamID:  1,
@@ -2119,7 +2315,7 @@ func emitFunctionsUpstreamFlow(b *strings.Builder, r *rng, opts Options, pool []
 	}
 	state.funcs = append(state.funcs, state.makeFuncSignature(r, 1))
 	state.built = append(state.built, false)
-	state.defs = append(state.defs, "")
+	state.defs = append(state.defs, nil)
 	if state.stmtBudget < 0 {
 		state.stmtBudget = -1
 	}
@@ -2131,71 +2327,121 @@ func emitFunctionsUpstreamFlow(b *strings.Builder, r *rng, opts Options, pool []
 		state.defs[cur] = emitSingleFuncDef(r, opts, state.funcs[cur], state, cur, maxBlock, env, info, &state.stmtBudget)
 		state.built[cur] = true
 	}
+	state.drawProgramAttributes(r)
 
-	if state.lateGlobals.Len() > 0 {
-		b.WriteString(state.lateGlobals.String())
-		writeLine(b, 0, "")
+	if len(state.lateGlobals) > 0 {
+		addDecl(f, state.lateGlobals...)
+		addDecl(f, &cast.Blank{})
 	}
-	emitFuncDecls(b, state.funcs)
-	writeLine(b, 0, "/* --- FUNCTIONS --- */")
-	writeLine(b, 0, "/* ------------------------------------------ */")
+	emitFuncDecls(f, state.funcs)
+	addDecl(f,
+		&cast.Comment{Text: "/* --- FUNCTIONS --- */"},
+		&cast.Comment{Text: "/* ------------------------------------------ */"},
+	)
 	for i := 0; i < len(state.defs); i++ {
-		b.WriteString(state.defs[i])
+		addDecl(f, state.defs[i], &cast.Blank{})
 	}
-	return state.funcs, state.dynGlobals
+	return state.funcs, state.dynGlobals, state.dynArrays
 }
 
-func emitComputeHashFunc(b *strings.Builder, env envInfo, info compositeInfo) {
-	writeLine(b, 0, "void csmith_compute_hash(int print_hash_value)")
-	writeLine(b, 0, "{")
+func emitComputeHashFunc(f *cast.File, env envInfo, info compositeInfo) {
+	def := &cast.FuncDef{
+		FuncDecl: cast.FuncDecl{
+			Ret:    &cast.Named{Name: "void"},
+			Name:   "csmith_compute_hash",
+			Params: []*cast.Param{{Type: &cast.Named{Name: "int"}, Name: "print_hash_value"}},
+		},
+		Body:           &cast.Block{},
+		BraceOnOwnLine: true,
+	}
+	u64 := &cast.Named{Name: "uint64_t"}
+	crc := func(v cast.Expr, t CType, name string) cast.Stmt {
+		if t.Float {
+			// Floating-point values are hashed through their object representation.
+			bytes := &cast.Cast{Type: &cast.Pointer{Elem: &cast.Named{Name: "char"}}, X: addrOf(v)}
+			return exprStmt(call("transparent_crc_bytes", bytes, call("sizeof", v), lit("%q", name), ident("print_hash_value")))
+		}
+		if t.Bits == 128 {
+			// Fold the high half in so it contributes to the checksum.
+			v = paren(binary(v, "^", paren(binary(v, ">>", lit("64")))))
+		}
+		return exprStmt(call("transparent_crc", &cast.Cast{Type: u64, X: v}, lit("%q", name), ident("print_hash_value")))
+	}
 	for _, g := range env.globals {
-		writeLine(b, 1, fmt.Sprintf("transparent_crc((uint64_t)%s, \"%s\", print_hash_value);", g.name, g.name))
+		if g.ctype.Aggregate {
+			for _, l := range info.leaves(g.ctype, true) {
+				addStmt(def.Body, crc(memberPath(ident(g.name), l.path), l.ctype, g.name+"."+l.path))
+			}
+			continue
+		}
+		addStmt(def.Body, crc(ident(g.name), g.ctype, g.name))
 	}
 	for _, arr := range env.arrays {
-		writeLine(b, 1, fmt.Sprintf("for (int i = 0; i < %d; i++)", arr.len))
-		writeLine(b, 2, fmt.Sprintf("transparent_crc((uint64_t)%s[i], \"%s[i]\", print_hash_value);", arr.name, arr.name))
+		if s := hashArray(arr, crc); s != nil {
+			addStmt(def.Body, s)
+		}
 	}
-	_ = info
-	writeLine(b, 0, "}")
-	writeLine(b, 0, "")
+	addDecl(f, def, &cast.Blank{})
 }
 
-func emitMain(b *strings.Builder, opts Options, env envInfo, info compositeInfo, entry string) {
+func emitMain(f *cast.File, opts Options, env envInfo, info compositeInfo, entry funcInfo, ext extension) {
 	useRuntime := opts.SafeMath || opts.ComputeHash
 	useHashPrintf := opts.HashValuePrintf
+	intType := &cast.Named{Name: "int"}
+	main := &cast.FuncDef{
+		FuncDecl: cast.FuncDecl{Ret: intType, Name: "main"},
+		Body:     &cast.Block{},
+	}
+	body := main.Body
+	printHash := &cast.VarDecl{Type: intType, Name: "print_hash_value", Init: lit("0")}
 	if opts.AcceptArgc {
-		writeLine(b, 0, "int main(int argc, char *argv[]) {")
-		writeLine(b, 1, "int print_hash_value = 0;")
-		if useRuntime && useHashPrintf {
-			writeLine(b, 1, "if (argc == 2 && strcmp(argv[1], \"1\") == 0) print_hash_value = 1;")
+		main.Params = []*cast.Param{
+			{Type: intType, Name: "argc"},
+			{Type: &cast.Array{Elem: &cast.Pointer{Elem: &cast.Named{Name: "char"}}, Len: -1}, Name: "argv"},
 		}
-	} else {
-		writeLine(b, 0, "int main(void) {")
-		if useRuntime {
-			writeLine(b, 1, "int print_hash_value = 0;")
+		addStmt(body, printHash)
+		if useRuntime && useHashPrintf {
+			cond := binary(
+				binary(ident("argc"), "==", lit("2")),
+				"&&",
+				binary(call("strcmp", index(ident("argv"), lit("1")), lit(`"1"`)), "==", lit("0")),
+			)
+			addStmt(body, &cast.If{Cond: cond, Then: exprStmt(assign(ident("print_hash_value"), "=", lit("1")))})
 		}
+	} else if useRuntime {
+		addStmt(body, printHash)
 	}
 
 	if useRuntime {
-		writeLine(b, 1, "platform_main_begin();")
+		addStmt(body, exprStmt(call("platform_main_begin")))
 		if opts.ComputeHash {
-			writeLine(b, 1, "crc32_gentab();")
+			addStmt(body, exprStmt(call("crc32_gentab")))
 		}
 	}
-	writeLine(b, 1, fmt.Sprintf("(void)%s();", entry))
+	if ext != nil {
+		ext.outputInit(body)
+		ext.outputInvocation(body, entry, opts)
+	} else {
+		emitEntryInvocation(body, entry, opts)
+	}
+	addStmt(body, &cast.Return{X: lit("0")})
+	addDecl(f, main)
+}
+
+// emitEntryInvocation calls the entry function, then hashes the globals and
+// reports the checksum.
+func emitEntryInvocation(body *cast.Block, entry funcInfo, opts Options) {
+	addStmt(body, exprStmt(entryCall(entry)))
 	if opts.ComputeHash {
-		writeLine(b, 1, "csmith_compute_hash(print_hash_value);")
-		if useRuntime {
-			writeLine(b, 1, "platform_main_end(crc32_context ^ 0xFFFFFFFFUL, print_hash_value);")
-		} else {
-			writeLine(b, 1, "platform_main_end(0,0);")
-		}
+		addStmt(body, exprStmt(call("csmith_compute_hash", ident("print_hash_value"))))
+		checksum := binary(ident("crc32_context"), "^", lit("0xFFFFFFFFUL"))
+		addStmt(body, exprStmt(call("platform_main_end", checksum, ident("print_hash_value"))))
+	} else if opts.SafeMath {
+		addStmt(body, exprStmt(call("platform_main_end", lit("0u"), lit("0"))))
 	}
-	if !opts.ComputeHash && useRuntime {
-		writeLine(b, 1, "platform_main_end(0u, 0);")
+	if opts.identifiesWrappers() {
+		addStmt(body, exprStmt(call("safe_math_report")))
 	}
-	writeLine(b, 1, "return 0;")
-	writeLine(b, 0, "}")
 }
 
 // Generate emits deterministic C code from options and seed.
@@ -2205,6 +2451,10 @@ func Generate(opts Options) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	opts, err = opts.resolveProbabilities()
+	if err != nil {
+		return "", err
+	}
 	opts = opts.normalizeUpstreamFlow()
 
 	if err := opts.validate(); err != nil {
@@ -2212,5 +2462,18 @@ func Generate(opts Options) (string, error) {
 	}
 	gen := createProgramGenerator(opts)
 	gen.initialize()
-	return gen.goGenerator(), nil
+	program := gen.goGenerator()
+	if fg, ok := gen.(failingGenerator); ok {
+		if err := fg.generationError(); err != nil {
+			return "", err
+		}
+	}
+	if eg, ok := gen.(extraOutputGenerator); ok {
+		for _, f := range eg.extraOutputs() {
+			if err := os.WriteFile(f.path, []byte(f.content), 0o644); err != nil {
+				return "", err
+			}
+		}
+	}
+	return program, nil
 }
@@ -0,0 +1,70 @@
+package csmith
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"csmith/pkg/csmith/cast"
+)
+
+// TestFloatConstantCPPMatchesC checks that the decimal literals of C++ mode
+// denote the same values as the hexadecimal ones written for C.
+func TestFloatConstantCPPMatchesC(t *testing.T) {
+	cpp := Defaults()
+	cpp.LangCPP = true
+	for _, ft := range []CType{{Name: "float", Bits: 32, Float: true}, {Name: "double", Bits: 64, Float: true}} {
+		for seed := uint64(1); seed <= 200; seed++ {
+			c := floatLiteral(t, floatConstantExpr(ft, newRNG(seed).next31, Defaults()))
+			d := floatLiteral(t, floatConstantExpr(ft, newRNG(seed).next31, cpp))
+			if strings.Contains(d, "0x") {
+				t.Fatalf("C++ literal %q is hexadecimal", d)
+			}
+			cv, err := strconv.ParseFloat(strings.TrimSuffix(c, "F"), ft.Bits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dv, err := strconv.ParseFloat(strings.TrimSuffix(d, "F"), ft.Bits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cv != dv {
+				t.Fatalf("%s: C literal %s = %g, C++ literal %s = %g", ft.Name, c, cv, d, dv)
+			}
+		}
+	}
+}
+
+// floatLiteral unwraps the literal from the cast around a float constant.
+func floatLiteral(t *testing.T, e cast.Expr) string {
+	t.Helper()
+	l, ok := e.(*cast.Paren).X.(*cast.Cast).X.(*cast.Paren).X.(*cast.Lit)
+	if !ok {
+		t.Fatalf("float constant %s is not a cast literal", cast.Print(e))
+	}
+	return l.Text
+}
+
+func TestAggregateTemporary(t *testing.T) {
+	s := CType{Name: "struct S0", Aggregate: true}
+	cpp := Defaults()
+	cpp.LangCPP = true
+	cpp11 := cpp
+	cpp11.CPP11 = true
+	for _, tc := range []struct {
+		opts Options
+		want string
+	}{
+		{Defaults(), "(struct S0){0}"},
+		{cpp, "S0()"},
+		{cpp11, "S0{}"},
+	} {
+		e := aggregateTemporary(s, tc.opts)
+		if got := cast.Print(e); got != tc.want {
+			t.Errorf("aggregateTemporary = %s, want %s", got, tc.want)
+		}
+		if tmp, ok := e.(*cast.Temporary); ok && tmp.Type.(*cast.Named).Name != "S0" {
+			t.Errorf("temporary of type %s, want S0", cast.TypeName(tmp.Type))
+		}
+	}
+}
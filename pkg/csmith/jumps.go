@@ -0,0 +1,162 @@
+package csmith
+
+import (
+	"fmt"
+
+	"csmith/pkg/csmith/cast"
+)
+
+// maxBackwardJumps bounds how many backward gotos one function invocation
+// takes, so every generated program still terminates.
+const maxBackwardJumps = 4
+
+// gotoSite is a generated "if (cond) goto L;" whose label is placed once the
+// enclosing function body is complete and the statements following it exist.
+type gotoSite struct {
+	stmt *cast.If
+	cond cast.Expr
+	// blocks are the blocks enclosing the jump, outermost first; pos holds,
+	// per block, the index of the statement that contains the jump.
+	blocks []*cast.Block
+	pos    []int
+	// backward, level and pick are drawn when the jump is generated: the
+	// direction, the enclosing block the label goes to, and which eligible
+	// statement in that block gets it.
+	backward bool
+	level    int
+	pick     uint32
+}
+
+// emitGoto appends a conditional jump to blk. Labels are only ever placed in
+// blk or a block enclosing it, so jumps never enter a scope.
+func emitGoto(blk *cast.Block, r *rng, cond cast.Expr, ctx *genContext) bool {
+	if ctx == nil || ctx.state == nil || len(ctx.blocks) == 0 || ctx.blocks[len(ctx.blocks)-1] != blk {
+		return false
+	}
+	site := gotoSite{
+		stmt:     &cast.If{Cond: cond, Then: &cast.Goto{}},
+		cond:     cond,
+		blocks:   append([]*cast.Block(nil), ctx.blocks...),
+		backward: r.flipcoin(50),
+		level:    int(r.upto(uint32(len(ctx.blocks)))),
+		pick:     r.next31(),
+	}
+	for _, b := range site.blocks {
+		// Enclosing statements are appended after their bodies, so each one
+		// lands at the current end of its block.
+		site.pos = append(site.pos, len(b.Stmts))
+	}
+	ctx.gotos = append(ctx.gotos, site)
+	addStmt(blk, site.stmt)
+	return true
+}
+
+// labelable reports whether a label may be attached to the j-th statement of
+// b. Declarations cannot be labelled, comments would leave the label dangling,
+// and returns are kept bare so the statements inserted before them run on
+// every path. The statement after an undefined-behaviour marker stays bare too,
+// so the marker and the manifest keep pointing at the faulty statement.
+func labelable(b *cast.Block, j int) bool {
+	if j > 0 && isUBMarker(b.Stmts[j-1]) {
+		return false
+	}
+	s := b.Stmts[j]
+	for {
+		l, ok := s.(*cast.Labeled)
+		if !ok {
+			break
+		}
+		s = l.Stmt
+	}
+	switch s.(type) {
+	case *cast.VarDecl, *cast.Comment, *cast.Return:
+		return false
+	}
+	return true
+}
+
+// resolveGotos places the label of every jump generated in body. prologue is
+// the number of leading declarations of body that no label may precede.
+// Forward jumps never skip a declaration; backward jumps are guarded by a
+// counter declared after the prologue, which limits them to maxBackwardJumps
+// per invocation.
+func resolveGotos(body *cast.Block, prologue int, ctx *genContext) {
+	if ctx == nil || len(ctx.gotos) == 0 {
+		return
+	}
+	counter := ""
+	labels := map[*cast.Block]map[int]string{}
+	for _, site := range ctx.gotos {
+		b, j, backward := site.target(body, prologue)
+		if labels[b] == nil {
+			labels[b] = map[int]string{}
+		}
+		name, ok := labels[b][j]
+		if !ok {
+			name = ctx.state.allocLabelName()
+			labels[b][j] = name
+			b.Stmts[j] = &cast.Labeled{Label: name, Stmt: b.Stmts[j]}
+		}
+		site.stmt.Then = &cast.Goto{Label: name}
+		if backward {
+			if counter == "" {
+				counter = ctx.state.allocLocalName()
+			}
+			bound := binary(&cast.Postfix{X: ident(counter), Op: "++"}, "<", lit("%du", maxBackwardJumps))
+			site.stmt.Cond = binary(paren(site.cond), "&&", paren(bound))
+		}
+	}
+	if counter != "" {
+		decl := &cast.VarDecl{Type: &cast.Named{Name: "uint32_t"}, Name: counter, Init: lit("0u")}
+		stmts := make([]cast.Stmt, 0, len(body.Stmts)+1)
+		stmts = append(stmts, body.Stmts[:prologue]...)
+		stmts = append(stmts, decl)
+		body.Stmts = append(stmts, body.Stmts[prologue:]...)
+	}
+	ctx.gotos = nil
+}
+
+// target picks the statement to label: in the drawn enclosing block, after
+// the jump for a forward goto, or at or before it for a backward one. A
+// forward jump without an eligible statement becomes a backward one; the jump
+// itself is always eligible as a backward target.
+func (s gotoSite) target(body *cast.Block, prologue int) (*cast.Block, int, bool) {
+	b, at := s.blocks[s.level], s.pos[s.level]
+	if !s.backward {
+		var eligible []int
+		for j := at + 1; j < len(b.Stmts); j++ {
+			if _, ok := b.Stmts[j].(*cast.VarDecl); ok {
+				// Jumping past an initialization would leave it unset.
+				break
+			}
+			if labelable(b, j) {
+				eligible = append(eligible, j)
+			}
+		}
+		if len(eligible) > 0 {
+			return b, eligible[int(s.pick%uint32(len(eligible)))], false
+		}
+	}
+	lo := 0
+	if b == body {
+		lo = prologue
+	}
+	var eligible []int
+	for j := lo; j <= at && j < len(b.Stmts); j++ {
+		if labelable(b, j) {
+			eligible = append(eligible, j)
+		}
+	}
+	if len(eligible) == 0 {
+		// Fall back to the jump itself.
+		last := len(s.blocks) - 1
+		return s.blocks[last], s.pos[last], true
+	}
+	return b, eligible[int(s.pick%uint32(len(eligible)))], true
+}
+
+func (s *functionFlowState) allocLabelName() string {
+	name := fmt.Sprintf("lbl_%d", s.nextLabelID)
+	s.nextLabelID++
+	return name
+}
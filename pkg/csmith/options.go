@@ -11,6 +11,10 @@ import (
 
 const defaultPlatformInfoPath = "platform.info"
 
+// defaultSplitFilesDir receives the units of a split program when
+// SplitFilesDir is not set.
+const defaultSplitFilesDir = "./output"
+
 // Options is the canonical API-level configuration contract for generation.
 // Defaults are aligned with Csmith's CGOptions::set_default_settings where possible.
 type Options struct {
@@ -145,6 +149,9 @@ type Options struct {
 	VariableAttributes       bool
 
 	StructOutput             string
+	UBManifest               string
+	WrapperSites             string
+	Runtime                  string
 	DFSDebugSequence         string
 	PartialExpand            string
 	DeltaMonitor             string
@@ -162,6 +169,18 @@ type Options struct {
 
 	// Keep an escape hatch for the current simplified generator shape.
 	MaxGlobals int
+
+	// probs is the probability table resolved from the options; nil means defaults.
+	probs *probTable
+	// probsGiven marks the entries --probability-configuration set, which
+	// --random-random leaves alone.
+	probsGiven map[probID]bool
+	// partialExpand holds the statement kinds --partial-expand lists; nil
+	// means every kind.
+	partialExpand map[stmtKind]bool
+	// builtinKinds holds the builtin kinds that may be called; nil means
+	// every kind.
+	builtinKinds map[string]bool
 }
 
 func Defaults() Options {
@@ -286,6 +305,9 @@ func Defaults() Options {
 		LabelAttributes:          false,
 		VariableAttributes:       false,
 		StructOutput:             "",
+		UBManifest:               "",
+		WrapperSites:             "",
+		Runtime:                  runtimeInclude,
 		DFSDebugSequence:         "",
 		PartialExpand:            "",
 		DeltaMonitor:             "",
@@ -420,6 +442,12 @@ func (o Options) Validate() error {
 		if o.Klee || o.Crest || o.CoverageTest {
 			return fmt.Errorf("exhaustive mode doesn't support klee|crest|coverage-test extension")
 		}
+		if _, err := parseDFSSequence(o.DFSDebugSequence); err != nil {
+			return err
+		}
+		if _, err := parsePartialExpand(o.PartialExpand); err != nil {
+			return err
+		}
 	}
 	if o.RandomBased {
 		if o.DFSExhaustive {
@@ -437,11 +465,16 @@ func (o Options) Validate() error {
 			return fmt.Errorf("split_files_dir can only be applied to random mode")
 		}
 	}
+	if o.DumpRandomProbabilities != "" && !o.RandomRandom {
+		return fmt.Errorf("--dump-random-probabilities can only be used with --random-random")
+	}
 	if o.DeltaMonitor != "" && o.GoDelta != "" {
 		return fmt.Errorf("you cannot specify --delta-monitor and --go-delta monitor at the same time")
 	}
-	if o.MaxSplitFiles > 0 && o.SplitFilesDir == "" {
-		o.SplitFilesDir = "./output"
+	if o.MaxSplitFiles > 0 {
+		if o.SplitFilesDir == "" {
+			o.SplitFilesDir = defaultSplitFilesDir
+		}
 		if err := os.MkdirAll(o.SplitFilesDir, 0o755); err != nil {
 			return fmt.Errorf("cannot create dir for split files: %w", err)
 		}
@@ -459,6 +492,37 @@ func (o Options) Validate() error {
 	if extCount > 1 {
 		return fmt.Errorf("you could only specify --klee or --crest or --coverage-test")
 	}
+	if _, err := parseBuiltinKinds(o.EnableBuiltinKinds); err != nil {
+		return err
+	}
+	if _, err := parseBuiltinKinds(o.DisableBuiltinKinds); err != nil {
+		return err
+	}
+	if o.CoverageTest && o.CoverageTestSize <= 0 {
+		return fmt.Errorf("coverage-test-size must be positive")
+	}
+	if _, err := parseSafeMathWrappers(o.SafeMathWrappers); err != nil {
+		return err
+	}
+	if o.WrapperSites != "" && !o.identifiesWrappers() {
+		return fmt.Errorf("wrapper-sites requires identify-wrappers and safe math")
+	}
+	if err := validRuntimeMode(o.Runtime); err != nil {
+		return err
+	}
+	// The site counters and safe_math_report only exist in the runtime this
+	// generator embeds, not in an upstream csmith.h.
+	if o.identifiesWrappers() && o.Runtime == runtimeInclude {
+		return fmt.Errorf("identify-wrappers requires --runtime inline or --runtime write")
+	}
+	// Nor do the floating-point wrappers.
+	if o.SafeMath && o.EnableFloat && o.Runtime == runtimeInclude {
+		return fmt.Errorf("float with safe math requires --runtime inline or --runtime write")
+	}
+	// Nor the 128-bit ones.
+	if o.SafeMath && (o.Int128 || o.UInt128) && o.Runtime == runtimeInclude {
+		return fmt.Errorf("int128 and uint128 with safe math require --runtime inline or --runtime write")
+	}
 	return nil
 }
 
@@ -475,10 +539,40 @@ func (o Options) normalizeUpstreamFlow() Options {
 		o.VolStructUnionFields = false
 		o.ConstStructUnionFields = false
 	}
+	// CompCert rejects packed structs, 128-bit integers, binary constants,
+	// volatile struct members and most GCC builtins, and of the GCC attributes
+	// only honours aligned, on types.
+	if o.CComp {
+		o.Builtins = false
+		o.FunctionAttributes = false
+		o.LabelAttributes = false
+		o.VariableAttributes = false
+		o.PackedStruct = false
+		o.Int128 = false
+		o.UInt128 = false
+		o.BinaryConstant = false
+		o.VolStructUnionFields = false
+	}
 	// Upstream DFS mode forces fixed struct fields.
 	if o.DFSExhaustive {
 		o.FixedStructFields = true
 	}
+	// Invalid lists are reported by Validate.
+	o.partialExpand, _ = parsePartialExpand(o.PartialExpand)
+	o.builtinKinds = resolveBuiltinKinds(o)
+	// Split files default to ./output.
+	if o.MaxSplitFiles > 0 && o.SplitFilesDir == "" {
+		o.SplitFilesDir = defaultSplitFilesDir
+	}
+	// The undefined-behaviour manifest defaults to sit next to an explicit
+	// output file; a program written to stdout gets one only on request.
+	if o.injectsUB() && o.UBManifest == "" && o.OutputPath != "" {
+		o.UBManifest = o.OutputPath + ".ub.json"
+	}
+	// So does the report of identified safe-math wrapper sites.
+	if o.identifiesWrappers() && o.WrapperSites == "" && o.OutputPath != "" {
+		o.WrapperSites = o.OutputPath + ".wrappers.json"
+	}
 	return o
 }
 
@@ -2,7 +2,9 @@ package csmith
 
 import (
 	"fmt"
-	"strings"
+	"path/filepath"
+
+	"csmith/pkg/csmith/cast"
 )
 
 // defaultProgramGenerator mirrors the high-level upstream flow:
@@ -11,61 +13,124 @@ type defaultProgramGenerator struct {
 	opts       Options
 	r          *rng
 	pool       []CType
-	b          strings.Builder
+	file       *cast.File
 	info       compositeInfo
 	env        envInfo
 	funcs      []funcInfo
 	dynGlobals []globalInfo
+	extra      []outputFile
+	ext        extension
 }
 
 func newDefaultProgramGenerator(opts Options) *defaultProgramGenerator {
-	return &defaultProgramGenerator{opts: opts}
+	return &defaultProgramGenerator{opts: opts, ext: newExtension(opts)}
 }
 
 func (g *defaultProgramGenerator) initialize() {
 	g.r = newRNG(g.opts.Seed)
+	var dump []outputFile
+	g.opts, dump = g.opts.randomizeProbabilities(g.r)
+	g.extra = append(g.extra, dump...)
 	g.pool = typePool(g.opts)
 }
 
 func (g *defaultProgramGenerator) outputHeader() {
-	g.b.WriteString("/*\n")
-	g.b.WriteString(" * This is a RANDOMLY GENERATED PROGRAM.\n")
-	g.b.WriteString(" *\n")
-	g.b.WriteString(" * Generator: csmith 2.3.0\n")
-	g.b.WriteString(" * Git version: 30dccd7\n")
-	g.b.WriteString(" * Options:   --seed ")
-	g.b.WriteString(fmt.Sprintf("%d", g.opts.Seed))
-	g.b.WriteString("\n")
-	g.b.WriteString(" * Seed:      ")
-	g.b.WriteString(fmt.Sprintf("%d", g.opts.Seed))
-	g.b.WriteString("\n")
-	g.b.WriteString(" */\n\n")
-	g.b.WriteString("#include \"csmith.h\"\n\n")
-	g.b.WriteString("static long __undefined;\n\n")
+	g.file = &cast.File{}
+	header := fmt.Sprintf(`/*
+ * This is a RANDOMLY GENERATED PROGRAM.
+ *
+ * Generator: csmith 2.3.0
+ * Git version: 30dccd7
+ * Options:   --seed %d
+ * Seed:      %d
+ */`, g.opts.Seed, g.opts.Seed)
+	addDecl(g.file,
+		&cast.Comment{Text: header},
+		&cast.Blank{},
+		runtimeDecl(g.opts),
+	)
+	if g.ext != nil {
+		for _, path := range g.ext.includes() {
+			addDecl(g.file, &cast.Include{Path: path})
+		}
+	}
+	addDecl(g.file,
+		&cast.Blank{},
+		&cast.VarDecl{Storage: "static", Type: &cast.Named{Name: "long"}, Name: "__undefined"},
+		&cast.Blank{},
+	)
 }
 
 func (g *defaultProgramGenerator) generateAllTypes() {
-	g.info = emitCompositeTypes(&g.b, g.r, g.opts, g.pool)
+	if g.opts.StructOutput == "" {
+		g.info = emitCompositeTypes(g.file, g.r, g.opts, g.pool)
+		return
+	}
+	// --struct-output: the declarations go to their own header, which the
+	// program includes in place of the inline definitions.
+	guard := includeGuard(g.opts.StructOutput)
+	types := &cast.File{}
+	addDecl(types,
+		&cast.Directive{Text: "ifndef " + guard},
+		&cast.Directive{Text: "define " + guard},
+		&cast.Blank{},
+	)
+	g.info = emitCompositeTypes(types, g.r, g.opts, g.pool)
+	addDecl(types, &cast.Directive{Text: "endif"})
+	g.extra = append(g.extra, outputFile{path: g.opts.StructOutput, content: cast.Print(types)})
+	addDecl(g.file, &cast.Include{Path: structOutputInclude(g.opts)}, &cast.Blank{})
+}
+
+// sourceDir returns the directory the program (or its split files) is written
+// to, or "" when it goes to standard output.
+func sourceDir(opts Options) string {
+	switch {
+	case opts.MaxSplitFiles > 0:
+		return opts.SplitFilesDir
+	case opts.OutputPath != "":
+		return filepath.Dir(opts.OutputPath)
+	}
+	return ""
+}
+
+// structOutputInclude returns the path under which the generated sources
+// include the --struct-output file: relative to the directory the program
+// (or its split files) is written to, when that is known.
+func structOutputInclude(opts Options) string {
+	dir := sourceDir(opts)
+	if dir == "" {
+		return opts.StructOutput
+	}
+	rel, err := filepath.Rel(dir, opts.StructOutput)
+	if err != nil {
+		return opts.StructOutput
+	}
+	return filepath.ToSlash(rel)
 }
 
 func (g *defaultProgramGenerator) generateFunctions() {
 	// Upstream does not pre-generate a random global pool before Function::make_first.
 	// Globals are introduced while function bodies are generated.
 	g.env = envInfo{}
-	g.funcs, g.dynGlobals = emitFunctionsUpstreamFlow(&g.b, g.r, g.opts, g.pool, g.opts.MaxBlockSize, g.env, g.info)
+	var arrays []arrayInfo
+	g.funcs, g.dynGlobals, arrays = emitFunctionsUpstreamFlow(g.file, g.r, g.opts, g.pool, g.opts.MaxBlockSize, g.env, g.info)
 	if len(g.dynGlobals) > 0 {
 		g.env.globals = append(g.env.globals, g.dynGlobals...)
 	}
+	g.env.arrays = append(g.env.arrays, arrays...)
 }
 
 func (g *defaultProgramGenerator) output() {
 	if g.opts.ComputeHash {
-		emitComputeHashFunc(&g.b, g.env, g.info)
+		emitComputeHashFunc(g.file, g.env, g.info)
 	}
 	if g.opts.NoMain || len(g.funcs) == 0 {
 		return
 	}
-	emitMain(&g.b, g.opts, g.env, g.info, g.funcs[0].name)
+	if g.ext != nil {
+		g.ext.generateValues(g.r, g.funcs[0], g.env, g.file)
+	}
+	emitMain(g.file, g.opts, g.env, g.info, g.funcs[0], g.ext)
 }
 
 func (g *defaultProgramGenerator) goGenerator() string {
@@ -73,5 +138,27 @@ func (g *defaultProgramGenerator) goGenerator() string {
 	g.generateAllTypes()
 	g.generateFunctions()
 	g.output()
-	return g.b.String()
+	sites := resolveSafeMathWrappers(g.file, g.opts)
+	program := cast.Print(g.file)
+	sources := []outputFile{{path: g.opts.OutputPath, content: program}}
+	if g.opts.MaxSplitFiles > 0 {
+		entry, extra := splitProgram(g.file, g.opts)
+		g.extra = append(g.extra, extra...)
+		program, sources = cast.Print(entry), extra
+	}
+	if g.opts.injectsUB() && g.opts.UBManifest != "" {
+		if sources[0].path == "" {
+			sources[0].path = "<stdout>"
+		}
+		g.extra = append(g.extra, outputFile{path: g.opts.UBManifest, content: ubManifest(sources)})
+	}
+	if g.opts.identifiesWrappers() && g.opts.WrapperSites != "" {
+		g.extra = append(g.extra, outputFile{path: g.opts.WrapperSites, content: wrapperSitesReport(sites)})
+	}
+	g.extra = append(g.extra, runtimeOutputs(g.opts)...)
+	return program
+}
+
+func (g *defaultProgramGenerator) extraOutputs() []outputFile {
+	return g.extra
 }
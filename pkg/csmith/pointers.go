@@ -0,0 +1,270 @@
+package csmith
+
+import (
+	"fmt"
+
+	"csmith/pkg/csmith/cast"
+)
+
+// pointerInfo describes a pointer variable of depth levels of indirection that
+// ultimately designates a scalar of type targetTy.
+type pointerInfo struct {
+	name     string
+	targetTy CType
+	// quals[0] qualifies the scalar target as seen through the pointer;
+	// quals[k] qualifies the pointer object at level k (quals[depth] is the
+	// pointer variable itself).
+	quals []cast.Qual
+	// owner is the index of the function whose local the chain points to,
+	// or -1 when the target has static storage.
+	owner int
+}
+
+func (p pointerInfo) depth() int {
+	return len(p.quals) - 1
+}
+
+// declType returns the declared type of the pointer variable.
+func (p pointerInfo) declType() cast.Type {
+	var t cast.Type = qualTypeNode(p.targetTy, p.quals[0]&cast.Const != 0, p.quals[0]&cast.Volatile != 0)
+	for k := 1; k <= p.depth(); k++ {
+		t = &cast.Pointer{Elem: t, Qual: p.quals[k]}
+	}
+	return t
+}
+
+// derefExpr dereferences the pointer down to its scalar target.
+func (p pointerInfo) derefExpr() cast.Expr {
+	var e cast.Expr = ident(p.name)
+	for k := 0; k < p.depth(); k++ {
+		e = deref(e)
+	}
+	return e
+}
+
+// assignable reports whether the scalar target may be written through p.
+func (p pointerInfo) assignable() bool {
+	return p.quals[0]&cast.Const == 0
+}
+
+func mergedPointers(env envInfo, ctx *genContext) []pointerInfo {
+	out := make([]pointerInfo, 0, len(env.pointers))
+	out = append(out, env.pointers...)
+	if ctx == nil || ctx.state == nil {
+		return out
+	}
+	for _, p := range ctx.state.dynPointers {
+		// Pointers to locals are only valid while their function runs.
+		if p.owner >= 0 && p.owner != ctx.from {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// pointerTarget is an object whose address starts a pointer chain.
+type pointerTarget struct {
+	expr  cast.Expr
+	ctype CType
+	qual  cast.Qual
+	local bool
+}
+
+// choosePointerTarget picks what a new pointer chain points to: a fresh global
+// initialized from hexDigits (as upstream does), an array element or aggregate
+// field, or, with --addr-taken-of-locals, a local of the current function.
+func choosePointerTarget(r *rng, opts Options, env envInfo, scope scopeInfo, ctx *genContext, hexDigits string) (pointerTarget, bool) {
+	switch r.upto(4) {
+	case 1:
+		var eligible []arrayInfo
+		for _, a := range mergedArrays(env, ctx) {
+			if a.pointer {
+				// &*g[i] is not an address constant.
+				continue
+			}
+			if a.field != "" {
+				l, ok := a.leaf()
+				// Members of packed aggregates may be misaligned.
+				if !ok || l.bitfield || l.packed || (l.inUnion && !opts.TakeUnionFieldAddr) {
+					continue
+				}
+			}
+			eligible = append(eligible, a)
+		}
+		if len(eligible) > 0 {
+			a := eligible[int(r.upto(uint32(len(eligible))))]
+			qual := cast.Qual(0)
+			if l, ok := a.leaf(); ok {
+				qual = l.qual
+			}
+			return pointerTarget{expr: a.randomAccess(r.upto), ctype: a.ctype, qual: qual}, true
+		}
+	case 2, 3:
+		if opts.AddrTakenOfLocals && ctx != nil {
+			locals := []pointerTarget{{expr: ident("x"), ctype: CType{Name: "uint32_t", Signed: false, Bits: 32}, local: true}}
+			for _, p := range scope.params {
+				if p.ctype.Aggregate {
+					continue
+				}
+				locals = append(locals, pointerTarget{expr: ident(p.name), ctype: p.ctype, local: true})
+			}
+			return locals[int(r.upto(uint32(len(locals))))], true
+		}
+	}
+	if ctx == nil || ctx.state == nil {
+		return pointerTarget{}, false
+	}
+	t := hostIntType(opts)
+	id := ctx.state.nextGlobalID
+	ctx.state.nextGlobalID = id + 1
+	name := fmt.Sprintf("g_%d", id)
+	ctx.state.lateGlobals = append(ctx.state.lateGlobals, &cast.VarDecl{
+		Storage: "static",
+		Type:    typeNode(t),
+		Name:    name,
+		Init:    castExpr(t, lit("0x%sL", hexDigits)),
+	})
+	ctx.state.dynGlobals = append(ctx.state.dynGlobals, globalInfo{name: name, ctype: t})
+	return pointerTarget{expr: ident(name), ctype: t}, true
+}
+
+// createPointerChain declares a ladder of 1..MaxPointerDepth pointer globals,
+// the first holding the address of target and each further one the address of
+// the previous, each level drawing its own qualifiers. Every level becomes a
+// usable pointer; the deepest one is returned.
+func createPointerChain(r *rng, opts Options, target pointerTarget, ctx *genContext) pointerInfo {
+	depth := 1 + int(r.upto(uint32(max(1, opts.MaxPointerDepth))))
+	quals := []cast.Qual{target.qual}
+	if opts.ConstPointers && r.flipcoin(opts.prob(probRegularConst)) {
+		// Read-only view of a writable object.
+		quals[0] |= cast.Const
+	}
+	owner := -1
+	if target.local {
+		owner = ctx.from
+	}
+	var last pointerInfo
+	prev := target.expr
+	for k := 1; k <= depth; k++ {
+		var q cast.Qual
+		if opts.VolatilePointers && r.flipcoin(opts.prob(probRegularVolatile)) {
+			q |= cast.Volatile
+		}
+		// A pointer to a local is bound when its function starts, so the
+		// first level cannot be const.
+		if opts.ConstPointers && !(target.local && k == 1) && r.flipcoin(opts.prob(probRegularConst)) {
+			q |= cast.Const
+		}
+		quals = append(quals, q)
+		id := ctx.state.nextGlobalID
+		ctx.state.nextGlobalID = id + 1
+		p := pointerInfo{
+			name:     fmt.Sprintf("g_%d", id),
+			targetTy: target.ctype,
+			quals:    append([]cast.Qual(nil), quals...),
+			owner:    owner,
+		}
+		var init cast.Expr = addrOf(prev)
+		if target.local && k == 1 {
+			init = nullPointer(opts)
+		}
+		ctx.state.lateGlobals = append(ctx.state.lateGlobals, &cast.VarDecl{
+			Storage: "static",
+			Type:    p.declType(),
+			Name:    p.name,
+			Init:    init,
+		})
+		if target.local && k == 1 {
+			ctx.state.localBindings = append(ctx.state.localBindings, localBinding{owner: owner, ptr: p.name, target: target.expr})
+		}
+		ctx.state.dynPointers = append(ctx.state.dynPointers, p)
+		prev = ident(p.name)
+		last = p
+	}
+	return last
+}
+
+// localBinding records a global pointer that must be pointed at a local when
+// its function starts.
+type localBinding struct {
+	owner  int
+	ptr    string
+	target cast.Expr
+}
+
+// bindLocalPointers inserts, right after the declaration at index at, the
+// assignments that point global pointers at the locals of function idx. With
+// --dangling-global-pointers those pointers are reset to null before every
+// return so they never outlive the locals.
+func bindLocalPointers(body *cast.Block, at int, idx int, opts Options, state *functionFlowState) {
+	if state == nil {
+		return
+	}
+	var binds, resets []cast.Stmt
+	for _, b := range state.localBindings {
+		if b.owner != idx {
+			continue
+		}
+		binds = append(binds, exprStmt(assign(ident(b.ptr), "=", addrOf(b.target))))
+		resets = append(resets, exprStmt(assign(ident(b.ptr), "=", nullPointer(opts))))
+	}
+	if len(binds) == 0 {
+		return
+	}
+	if opts.DanglingGlobalPointers {
+		resetBeforeReturns(body, resets)
+	}
+	stmts := make([]cast.Stmt, 0, len(body.Stmts)+len(binds))
+	stmts = append(stmts, body.Stmts[:at]...)
+	stmts = append(stmts, binds...)
+	body.Stmts = append(stmts, body.Stmts[at:]...)
+}
+
+func resetBeforeReturns(blk *cast.Block, resets []cast.Stmt) {
+	out := make([]cast.Stmt, 0, len(blk.Stmts))
+	for _, s := range blk.Stmts {
+		switch s := s.(type) {
+		case *cast.Return:
+			out = append(out, resets...)
+		case *cast.Block:
+			resetBeforeReturns(s, resets)
+		case *cast.If:
+			if b, ok := s.Then.(*cast.Block); ok {
+				resetBeforeReturns(b, resets)
+			}
+			if b, ok := s.Else.(*cast.Block); ok {
+				resetBeforeReturns(b, resets)
+			}
+		case *cast.For:
+			if b, ok := s.Body.(*cast.Block); ok {
+				resetBeforeReturns(b, resets)
+			}
+		}
+		out = append(out, s)
+	}
+	blk.Stmts = out
+}
+
+// pointerCompareExpr compares a pointer with another of the same type, or
+// with null when there is none, yielding an int converted to t.
+func pointerCompareExpr(t CType, r *rng, op uint32, opts Options, env envInfo, ctx *genContext) (cast.Expr, bool) {
+	ptrs := mergedPointers(env, ctx)
+	if len(ptrs) == 0 {
+		return nil, false
+	}
+	p := ptrs[int(r.upto(uint32(len(ptrs))))]
+	other := nullPointer(opts)
+	pt := cast.TypeName(p.declType())
+	for _, q := range ptrs {
+		if q.name != p.name && cast.TypeName(q.declType()) == pt {
+			other = ident(q.name)
+			break
+		}
+	}
+	cmp := "=="
+	if op%2 == 1 {
+		cmp = "!="
+	}
+	return castExpr(t, paren(binary(ident(p.name), cmp, other))), true
+}
@@ -0,0 +1,583 @@
+package csmith
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// probID names one entry of the probability table, mirroring upstream ProbName.
+type probID int
+
+const (
+	// Single probabilities: percentages consulted with flipcoin.
+	probMoreStructUnionType probID = iota
+	probBitfieldsCreation
+	probBitfieldsSigned
+	probBitfieldInNormalStruct
+	probScalarFieldInFullBitfields
+	probSafeOpsSigned
+	probSelectDerefPointer
+	probRegularVolatile
+	probRegularConst
+	probFieldVolatile
+	probFieldConst
+	probStdUnaryFunc
+	probShiftByNonConstant
+	probNewArrayVariable
+	probInlineFunction
+	probBuiltinFunction
+	probFuncAttr
+	probTypeAttr
+	probLabelAttr
+	probVarAttr
+
+	// Statement group: relative weights of the statement kinds.
+	probStatementAssign
+	probStatementBlock
+	probStatementFor
+	probStatementIfElse
+	probStatementInvoke
+	probStatementReturn
+	probStatementContinue
+	probStatementBreak
+	probStatementGoto
+	probStatementArrayOp
+
+	// Simple type group, in upstream eSimpleType order.
+	probVoid
+	probChar
+	probInt
+	probShort
+	probLong
+	probLongLong
+	probUChar
+	probUInt
+	probUShort
+	probULong
+	probULongLong
+	probFloat
+
+	// Safe-math operand size group.
+	probSafeOpsSizeInt8
+	probSafeOpsSizeInt16
+	probSafeOpsSizeInt32
+	probSafeOpsSizeInt64
+
+	// Unary operator group, in unaryOps order.
+	probUnaryPlus
+	probUnaryMinus
+	probUnaryNot
+	probUnaryBitNot
+
+	// Binary operator group, in binaryOps order.
+	probBinaryAdd
+	probBinarySub
+	probBinaryMul
+	probBinaryDiv
+	probBinaryMod
+	probBinaryGt
+	probBinaryLt
+	probBinaryGe
+	probBinaryLe
+	probBinaryEq
+	probBinaryNe
+	probBinaryAnd
+	probBinaryOr
+	probBinaryBitXor
+	probBinaryBitAnd
+	probBinaryBitOr
+	probBinaryBitRshift
+	probBinaryBitLshift
+
+	probCount
+)
+
+// noProb stands for "no table entry".
+const noProb probID = -1
+
+// probGroup identifies a set of entries drawn together. The statement group
+// holds relative weights. The other groups are "equal" groups, as upstream
+// calls them: every member is drawn with the same chance, and a value of 0
+// takes the member out of the draw.
+type probGroup int
+
+const (
+	probSingle probGroup = iota
+	probGroupStatement
+	probGroupSimpleTypes
+	probGroupSafeOpsSize
+	probGroupUnaryOps
+	probGroupBinaryOps
+)
+
+// probGroups lists the groups in the order they are written out, with the
+// upstream name that may lead their line.
+var probGroups = []struct {
+	group probGroup
+	name  string
+}{
+	{probGroupStatement, "statement_prob"},
+	{probGroupSimpleTypes, "simple_types_prob"},
+	{probGroupSafeOpsSize, "safe_ops_size_prob"},
+	{probGroupUnaryOps, "unary_ops_prob"},
+	{probGroupBinaryOps, "binary_ops_prob"},
+}
+
+type probEntry struct {
+	name  string
+	group probGroup
+	def   int
+}
+
+// probEntries lists every table entry with its upstream name and default
+// (Probabilities.cpp set_default_probabilities). Group entries are listed in
+// the order their weights are laid out on the dice.
+var probEntries = [probCount]probEntry{
+	probMoreStructUnionType:        {"more_struct_union_type_prob", probSingle, 50},
+	probBitfieldsCreation:          {"bitfields_creation_prob", probSingle, 50},
+	probBitfieldsSigned:            {"bitfields_signed_prob", probSingle, 50},
+	probBitfieldInNormalStruct:     {"bitfield_in_normal_struct_prob", probSingle, 10},
+	probScalarFieldInFullBitfields: {"scalar_field_in_full_bitfields_prob", probSingle, 10},
+	probSafeOpsSigned:              {"safe_ops_signed_prob", probSingle, 50},
+	probSelectDerefPointer:         {"select_deref_pointer_prob", probSingle, 80},
+	probRegularVolatile:            {"regular_volatile_prob", probSingle, 50},
+	probRegularConst:               {"regular_const_prob", probSingle, 10},
+	probFieldVolatile:              {"field_volatile_prob", probSingle, 30},
+	probFieldConst:                 {"field_const_prob", probSingle, 20},
+	probStdUnaryFunc:               {"std_unary_func_prob", probSingle, 5},
+	probShiftByNonConstant:         {"shift_by_non_constant_prob", probSingle, 50},
+	probNewArrayVariable:           {"new_array_var_prob", probSingle, 20},
+	probInlineFunction:             {"inline_function_prob", probSingle, 50},
+	probBuiltinFunction:            {"builtin_function_prob", probSingle, 50},
+	probFuncAttr:                   {"func_attr_flag", probSingle, 30},
+	probTypeAttr:                   {"type_attr_flag", probSingle, 30},
+	probLabelAttr:                  {"label_attr_flag", probSingle, 30},
+	probVarAttr:                    {"var_attr_flag", probSingle, 30},
+
+	probStatementAssign:   {"statement_assign_prob", probGroupStatement, 40},
+	probStatementBlock:    {"statement_block_prob", probGroupStatement, 0},
+	probStatementFor:      {"statement_for_prob", probGroupStatement, 15},
+	probStatementIfElse:   {"statement_ifelse_prob", probGroupStatement, 15},
+	probStatementInvoke:   {"statement_invoke_prob", probGroupStatement, 0},
+	probStatementReturn:   {"statement_return_prob", probGroupStatement, 5},
+	probStatementContinue: {"statement_continue_prob", probGroupStatement, 5},
+	probStatementBreak:    {"statement_break_prob", probGroupStatement, 5},
+	probStatementGoto:     {"statement_goto_prob", probGroupStatement, 5},
+	probStatementArrayOp:  {"statement_arrayop_prob", probGroupStatement, 10},
+
+	probVoid:      {"void_prob", probGroupSimpleTypes, 0},
+	probChar:      {"char_prob", probGroupSimpleTypes, 1},
+	probInt:       {"int_prob", probGroupSimpleTypes, 1},
+	probShort:     {"short_prob", probGroupSimpleTypes, 1},
+	probLong:      {"long_prob", probGroupSimpleTypes, 1},
+	probLongLong:  {"long_long_prob", probGroupSimpleTypes, 1},
+	probUChar:     {"uchar_prob", probGroupSimpleTypes, 1},
+	probUInt:      {"uint_prob", probGroupSimpleTypes, 1},
+	probUShort:    {"ushort_prob", probGroupSimpleTypes, 1},
+	probULong:     {"ulong_prob", probGroupSimpleTypes, 1},
+	probULongLong: {"ulong_long_prob", probGroupSimpleTypes, 1},
+	probFloat:     {"float_prob", probGroupSimpleTypes, 1},
+
+	probSafeOpsSizeInt8:  {"safe_ops_size_int8", probGroupSafeOpsSize, 1},
+	probSafeOpsSizeInt16: {"safe_ops_size_int16", probGroupSafeOpsSize, 1},
+	probSafeOpsSizeInt32: {"safe_ops_size_int32", probGroupSafeOpsSize, 1},
+	probSafeOpsSizeInt64: {"safe_ops_size_int64", probGroupSafeOpsSize, 1},
+
+	probUnaryPlus:   {"unary_plus_prob", probGroupUnaryOps, 1},
+	probUnaryMinus:  {"unary_minus_prob", probGroupUnaryOps, 1},
+	probUnaryNot:    {"unary_not_prob", probGroupUnaryOps, 1},
+	probUnaryBitNot: {"unary_bit_not_prob", probGroupUnaryOps, 1},
+
+	probBinaryAdd:       {"binary_add_prob", probGroupBinaryOps, 1},
+	probBinarySub:       {"binary_sub_prob", probGroupBinaryOps, 1},
+	probBinaryMul:       {"binary_mul_prob", probGroupBinaryOps, 1},
+	probBinaryDiv:       {"binary_div_prob", probGroupBinaryOps, 1},
+	probBinaryMod:       {"binary_mod_prob", probGroupBinaryOps, 1},
+	probBinaryGt:        {"binary_gt_prob", probGroupBinaryOps, 1},
+	probBinaryLt:        {"binary_lt_prob", probGroupBinaryOps, 1},
+	probBinaryGe:        {"binary_ge_prob", probGroupBinaryOps, 1},
+	probBinaryLe:        {"binary_le_prob", probGroupBinaryOps, 1},
+	probBinaryEq:        {"binary_eq_prob", probGroupBinaryOps, 1},
+	probBinaryNe:        {"binary_ne_prob", probGroupBinaryOps, 1},
+	probBinaryAnd:       {"binary_and_prob", probGroupBinaryOps, 1},
+	probBinaryOr:        {"binary_or_prob", probGroupBinaryOps, 1},
+	probBinaryBitXor:    {"binary_bit_xor_prob", probGroupBinaryOps, 1},
+	probBinaryBitAnd:    {"binary_bit_and_prob", probGroupBinaryOps, 1},
+	probBinaryBitOr:     {"binary_bit_or_prob", probGroupBinaryOps, 1},
+	probBinaryBitRshift: {"binary_bit_rshift_prob", probGroupBinaryOps, 1},
+	probBinaryBitLshift: {"binary_bit_lshift_prob", probGroupBinaryOps, 1},
+}
+
+// ignoredUpstreamProbs are upstream probabilities for decisions this generator
+// does not make: qualifier loosening when matching pointers, exhaustive
+// bit-field layouts, the choice of the assignment target's type and
+// ACCESS_ONCE reads. A probability file may set them, so profiles written by
+// upstream load, but they have no effect and are not written out.
+var ignoredUpstreamProbs = map[string]bool{
+	"exhaustive_bitfield_prob": true,
+	"stricter_const_prob":      true,
+	"looser_const_prob":        true,
+	"pointer_as_ltype_prob":    true,
+	"struct_as_ltype_prob":     true,
+	"union_as_ltype_prob":      true,
+	"float_as_ltype_prob":      true,
+	"access_once_var_prob":     true,
+}
+
+// probTable holds the value of every probability entry.
+type probTable [probCount]int
+
+func defaultProbTable(o Options) *probTable {
+	t := &probTable{}
+	for id, e := range probEntries {
+		t[id] = e.def
+	}
+	// Probabilities that also have a dedicated command-line option start from it.
+	t[probInlineFunction] = o.InlineFunctionProb
+	t[probBuiltinFunction] = o.BuiltinFunctionProb
+	// As upstream, types and operators the options rule out start disabled.
+	if !o.LongLong {
+		t[probLongLong], t[probULongLong] = 0, 0
+	}
+	if !o.EnableFloat {
+		t[probFloat] = 0
+	}
+	if !o.UnaryPlusOperator {
+		t[probUnaryPlus] = 0
+	}
+	if !o.Muls {
+		t[probBinaryMul] = 0
+	}
+	if !o.Divs {
+		t[probBinaryDiv], t[probBinaryMod] = 0, 0
+	}
+	return t
+}
+
+func probByName(name string) (probID, bool) {
+	for id, e := range probEntries {
+		if e.name == name {
+			return probID(id), true
+		}
+	}
+	return 0, false
+}
+
+func groupMembers(g probGroup) []probID {
+	var out []probID
+	for id, e := range probEntries {
+		if e.group == g {
+			out = append(out, probID(id))
+		}
+	}
+	return out
+}
+
+// parse applies a probability file read from r on top of t and, when given is
+// not nil, records the ids it sets there. The syntax is the upstream one:
+// "name=value" for single probabilities, "[group,a=1,b=2,...]" for a group
+// (every member listed, the leading group name optional), and "#" comments.
+func (t *probTable) parse(r io.Reader, path string, given map[probID]bool) error {
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return fmt.Errorf("%s:%d: unterminated probability group", path, lineNo)
+			}
+			if err := t.parseGroup(line[1:len(line)-1], path, lineNo, given); err != nil {
+				return err
+			}
+			continue
+		}
+		if name, _, _ := strings.Cut(line, "="); ignoredUpstreamProbs[strings.TrimSpace(name)] {
+			continue
+		}
+		id, v, err := parseProbAssignment(line, path, lineNo)
+		if err != nil {
+			return err
+		}
+		if probEntries[id].group != probSingle {
+			return fmt.Errorf("%s:%d: %s must be set inside its [...] group", path, lineNo, probEntries[id].name)
+		}
+		if v > 100 {
+			return fmt.Errorf("%s:%d: %s value must between [0,100]", path, lineNo, probEntries[id].name)
+		}
+		t[id] = v
+		if given != nil {
+			given[id] = true
+		}
+	}
+	return scanner.Err()
+}
+
+func (t *probTable) parseGroup(body string, path string, lineNo int, given map[probID]bool) error {
+	group := probSingle
+	seen := make(map[probID]int)
+	items := strings.Split(body, ",")
+	if lead := strings.TrimSpace(items[0]); !strings.Contains(lead, "=") {
+		for _, pg := range probGroups {
+			if pg.name == lead {
+				group = pg.group
+			}
+		}
+		if group == probSingle {
+			return fmt.Errorf("%s:%d: unknown probability group %q", path, lineNo, lead)
+		}
+		items = items[1:]
+	}
+	for _, item := range items {
+		id, v, err := parseProbAssignment(strings.TrimSpace(item), path, lineNo)
+		if err != nil {
+			return err
+		}
+		g := probEntries[id].group
+		if g == probSingle || (group != probSingle && g != group) {
+			return fmt.Errorf("%s:%d: %s does not belong to this group", path, lineNo, probEntries[id].name)
+		}
+		group = g
+		seen[id] = v
+	}
+	total := 0
+	for _, id := range groupMembers(group) {
+		v, ok := seen[id]
+		if !ok {
+			return fmt.Errorf("%s:%d: group is missing %s", path, lineNo, probEntries[id].name)
+		}
+		total += v
+	}
+	if total <= 0 {
+		return fmt.Errorf("%s:%d: group probabilities must not all be zero", path, lineNo)
+	}
+	for id, v := range seen {
+		t[id] = v
+		if given != nil {
+			given[id] = true
+		}
+	}
+	return nil
+}
+
+func parseProbAssignment(s string, path string, lineNo int) (probID, int, error) {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return 0, 0, fmt.Errorf("%s:%d: expected name=value, got %q", path, lineNo, s)
+	}
+	name = strings.TrimSpace(name)
+	id, ok := probByName(name)
+	if !ok {
+		return 0, 0, fmt.Errorf("%s:%d: unknown probability %q", path, lineNo, name)
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || v < 0 {
+		return 0, 0, fmt.Errorf("%s:%d: invalid value for %s", path, lineNo, name)
+	}
+	return id, v, nil
+}
+
+// dump writes t in the syntax accepted by parse.
+func (t *probTable) dump(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# csmith probability configuration")
+	fmt.Fprintln(bw, "# single probabilities are percentages; statement_prob holds relative weights,")
+	fmt.Fprintln(bw, "# the other groups 0 (off) or 1 (on) per member")
+	fmt.Fprintln(bw)
+	for id, e := range probEntries {
+		if e.group == probSingle {
+			fmt.Fprintf(bw, "%s=%d\n", e.name, t[id])
+		}
+	}
+	for _, pg := range probGroups {
+		items := []string{pg.name}
+		for _, id := range groupMembers(pg.group) {
+			items = append(items, fmt.Sprintf("%s=%d", probEntries[id].name, t[id]))
+		}
+		fmt.Fprintln(bw)
+		fmt.Fprintf(bw, "[%s]\n", strings.Join(items, ","))
+	}
+	return bw.Flush()
+}
+
+func writeProbabilities(path string, t *probTable) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot write probabilities: %w", err)
+	}
+	if err := t.dump(f); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write probabilities: %w", err)
+	}
+	return f.Close()
+}
+
+// randomize redraws t from r, as --random-random does, walking the entries in
+// table order like upstream. Entries in keep, and the ones backed by a
+// dedicated command-line option, stay as they are. A single probability of 0
+// or 100 stays off or always on, otherwise it becomes a uniform percentage.
+// Statement weights are redrawn the same way, and an equal group flips a coin
+// for each member it has switched on. A group that comes out with nothing to
+// draw keeps its previous values; for the simple types that means no integer
+// type.
+func (t *probTable) randomize(r *rng, keep map[probID]bool) {
+	for id, e := range probEntries {
+		id := probID(id)
+		if e.group != probSingle || keep[id] || id == probInlineFunction || id == probBuiltinFunction {
+			continue
+		}
+		if t[id] != 0 && t[id] != 100 {
+			t[id] = int(r.upto(101))
+		}
+	}
+	for _, pg := range probGroups {
+		members := groupMembers(pg.group)
+		vals := make([]int, len(members))
+		total := 0
+		for i, id := range members {
+			vals[i] = t[id]
+			switch {
+			case keep[id] || t[id] == 0:
+			case pg.group == probGroupStatement:
+				vals[i] = int(r.upto(101))
+			case r.flipcoin(50):
+				vals[i] = 1
+			default:
+				vals[i] = 0
+			}
+			if pg.group != probGroupSimpleTypes || (id != probVoid && id != probFloat) {
+				total += vals[i]
+			}
+		}
+		if total == 0 {
+			continue
+		}
+		for i, id := range members {
+			t[id] = vals[i]
+		}
+	}
+}
+
+// resolveProbabilities builds the probability table used for generation from
+// --probability-configuration and honours --dump-default-probabilities.
+// --random-random redraws the table later, from the generator's own stream
+// (see randomizeProbabilities).
+func (o Options) resolveProbabilities() (Options, error) {
+	if path := strings.TrimSpace(o.DumpDefaultProbabilities); path != "" {
+		if err := writeProbabilities(path, defaultProbTable(o)); err != nil {
+			return o, err
+		}
+	}
+	t := defaultProbTable(o)
+	if path := strings.TrimSpace(o.ProbabilityConfiguration); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return o, fmt.Errorf("cannot open probability configuration: %w", err)
+		}
+		defer f.Close()
+		o.probsGiven = make(map[probID]bool)
+		if err := t.parse(f, path, o.probsGiven); err != nil {
+			return o, err
+		}
+	}
+	o.probs = t
+	pool := typePool(o)
+	disabled := disabledTypeFilter(pool, o)
+	for i, ct := range pool {
+		if !ct.Float && !disabled(uint32(i)) {
+			return o, nil
+		}
+	}
+	return o, fmt.Errorf("simple_types_prob must enable at least one integer type")
+}
+
+// randomizeProbabilities applies --random-random: upstream draws the table
+// from the generator's stream before generating anything, so r must be the
+// fresh generator stream. The table is copied, as Options are shared by value.
+// The returned file holds the --dump-random-probabilities output, if asked for.
+func (o Options) randomizeProbabilities(r *rng) (Options, []outputFile) {
+	if !o.RandomRandom {
+		return o, nil
+	}
+	t := *o.probTable()
+	t.randomize(r, o.probsGiven)
+	o.probs = &t
+	path := strings.TrimSpace(o.DumpRandomProbabilities)
+	if path == "" {
+		return o, nil
+	}
+	var b strings.Builder
+	_ = t.dump(&b)
+	return o, []outputFile{{path: path, content: b.String()}}
+}
+
+// groupFilter rejects the members of the equal group starting at first that
+// the table switches off, for uptoWithFilter over the group.
+func (o Options) groupFilter(first probID) func(uint32) bool {
+	return func(i uint32) bool {
+		return o.prob(first+probID(i)) == 0
+	}
+}
+
+// prob returns the value of a single probability.
+func (o Options) prob(id probID) uint32 {
+	return uint32(o.probTable()[id])
+}
+
+// probTable returns the resolved table, or the defaults when there is none.
+func (o Options) probTable() *probTable {
+	if o.probs == nil {
+		return defaultProbTable(o)
+	}
+	return o.probs
+}
+
+// stmtWeight is one slot of the statement dice.
+type stmtWeight struct {
+	kind   stmtKind
+	weight int
+}
+
+// statementWeights lays out the statement group on the dice. Kinds disabled by
+// options fold their weight into assignment so the dice range is unchanged.
+func (o Options) statementWeights() ([]stmtWeight, int) {
+	kinds := []struct {
+		id      probID
+		kind    stmtKind
+		enabled bool
+	}{
+		{probStatementIfElse, stmtIfElse, true},
+		{probStatementFor, stmtFor, true},
+		{probStatementBlock, stmtBlock, true},
+		{probStatementInvoke, stmtInvoke, true},
+		{probStatementReturn, stmtReturn, true},
+		{probStatementContinue, stmtContinue, true},
+		{probStatementBreak, stmtBreak, true},
+		{probStatementGoto, stmtGoto, o.Jumps},
+		{probStatementArrayOp, stmtArrayOp, o.Arrays},
+	}
+	out := make([]stmtWeight, 0, len(kinds)+1)
+	assign := int(o.prob(probStatementAssign))
+	total := assign
+	for _, k := range kinds {
+		w := int(o.prob(k.id))
+		total += w
+		if !k.enabled {
+			assign += w
+			continue
+		}
+		out = append(out, stmtWeight{kind: k.kind, weight: w})
+	}
+	out = append(out, stmtWeight{kind: stmtAssign, weight: assign})
+	return out, total
+}
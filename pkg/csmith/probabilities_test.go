@@ -0,0 +1,99 @@
+package csmith
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProbabilityDumpRoundTrip(t *testing.T) {
+	want := defaultProbTable(Defaults())
+	want[probStatementBlock] = 7
+	want[probBinaryMod] = 0
+	var buf bytes.Buffer
+	if err := want.dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got probTable
+	if err := got.parse(&buf, "dump", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got != *want {
+		t.Fatalf("parse(dump(t)) != t\ngot  %v\nwant %v", got, *want)
+	}
+}
+
+func TestProbabilityParse(t *testing.T) {
+	const profile = `# upstream-style profile
+stricter_const_prob=10
+shift_by_non_constant_prob=25
+[unary_plus_prob=0,unary_minus_prob=1,unary_not_prob=1,unary_bit_not_prob=1]
+[safe_ops_size_prob,safe_ops_size_int8=0,safe_ops_size_int16=0,safe_ops_size_int32=1,safe_ops_size_int64=1]
+`
+	tbl := defaultProbTable(Defaults())
+	if err := tbl.parse(strings.NewReader(profile), "profile", nil); err != nil {
+		t.Fatal(err)
+	}
+	if tbl[probShiftByNonConstant] != 25 || tbl[probUnaryPlus] != 0 || tbl[probSafeOpsSizeInt8] != 0 || tbl[probSafeOpsSizeInt64] != 1 {
+		t.Fatalf("profile not applied: %v", *tbl)
+	}
+
+	for _, bad := range []string{
+		"bogus_prob=1",
+		"regular_const_prob=101",
+		"statement_assign_prob=10",
+		"[foo_prob,unary_plus_prob=1]",
+		"[unary_ops_prob,unary_plus_prob=1]",
+		"[unary_plus_prob=1,unary_minus_prob=1,unary_not_prob=1,binary_add_prob=1]",
+		"[unary_plus_prob=0,unary_minus_prob=0,unary_not_prob=0,unary_bit_not_prob=0]",
+	} {
+		tbl := defaultProbTable(Defaults())
+		if err := tbl.parse(strings.NewReader(bad), "bad", nil); err == nil {
+			t.Errorf("parse(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestProbabilityTableNeedsIntegerType(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "probs")
+	profile := "[simple_types_prob,void_prob=0,char_prob=0,int_prob=0,short_prob=0,long_prob=0,long_long_prob=0,uchar_prob=0,uint_prob=0,ushort_prob=0,ulong_prob=0,ulong_long_prob=0,float_prob=1]\n"
+	if err := os.WriteFile(path, []byte(profile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := Defaults()
+	opts.EnableFloat = true
+	opts.ProbabilityConfiguration = path
+	if _, err := opts.resolveProbabilities(); err == nil || !strings.Contains(err.Error(), "integer type") {
+		t.Fatalf("resolveProbabilities = %v, want an error for a table without integer types", err)
+	}
+}
+
+func TestProbabilityRandomizeKeepsFixedEntries(t *testing.T) {
+	const profile = "regular_const_prob=37\n[safe_ops_size_prob,safe_ops_size_int8=1,safe_ops_size_int16=0,safe_ops_size_int32=1,safe_ops_size_int64=1]\n"
+	given := make(map[probID]bool)
+	tbl := defaultProbTable(Defaults())
+	if err := tbl.parse(strings.NewReader(profile), "profile", given); err != nil {
+		t.Fatal(err)
+	}
+	tbl[probFieldConst] = 100
+	orig := *tbl
+	for seed := uint64(1); seed <= 50; seed++ {
+		got := orig
+		got.randomize(newRNG(seed), given)
+		for id, e := range probEntries {
+			id := probID(id)
+			if given[id] || orig[id] == 0 || (e.group == probSingle && orig[id] == 100) {
+				if got[id] != orig[id] {
+					t.Fatalf("seed %d: %s = %d, want %d kept", seed, e.name, got[id], orig[id])
+				}
+			}
+		}
+		again := orig
+		again.randomize(newRNG(seed), given)
+		if again != got {
+			t.Fatalf("seed %d: randomize is not deterministic", seed)
+		}
+	}
+}
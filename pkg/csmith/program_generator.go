@@ -7,9 +7,28 @@ type absProgramGenerator interface {
 	goGenerator() string
 }
 
+// outputFile is a file produced alongside the program text.
+type outputFile struct {
+	path    string
+	content string
+}
+
+// extraOutputGenerator is implemented by generators that produce files besides
+// the program returned by goGenerator; they are written once generation is done.
+type extraOutputGenerator interface {
+	extraOutputs() []outputFile
+}
+
+// failingGenerator is implemented by generators whose run can fail, e.g. when
+// a replayed decision sequence does not lead to a program.
+type failingGenerator interface {
+	generationError() error
+}
+
 func createProgramGenerator(opts Options) absProgramGenerator {
 	// Upstream picks DFSProgramGenerator when dfs-exhaustive is enabled.
-	// This port still routes both modes through the same concrete generator.
-	// The mode-specific behavior is handled by option normalization/filters.
+	if opts.DFSExhaustive {
+		return newDFSProgramGenerator(opts)
+	}
 	return newDefaultProgramGenerator(opts)
 }
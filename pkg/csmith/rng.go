@@ -20,6 +20,7 @@ type rng struct {
 	traceRaw  bool
 	traceFile string
 	tracePos  uint64
+	dfs       *dfsSequence
 }
 
 func newRNG(seed uint64) *rng {
@@ -39,6 +40,9 @@ func newRNG(seed uint64) *rng {
 }
 
 func (r *rng) next31() uint32 {
+	if r.dfs != nil {
+		return r.dfs.tail.next31()
+	}
 	r.state = (lcgA*r.state + lcgC) & lcgMask
 	return uint32(r.state >> 17)
 }
@@ -47,6 +51,9 @@ func (r *rng) upto(n uint32) uint32 {
 	if n == 0 {
 		return 0
 	}
+	if r.dfs != nil {
+		return r.dfs.upto(n, nil)
+	}
 	raw := r.next31()
 	x := raw % n
 	r.traceU(n, x, 0, raw)
@@ -57,6 +64,9 @@ func (r *rng) uptoWithFilter(n uint32, reject func(uint32) bool) uint32 {
 	if n == 0 {
 		return 0
 	}
+	if r.dfs != nil {
+		return r.dfs.upto(n, reject)
+	}
 	raw := r.next31()
 	x := raw % n
 	if reject == nil || !reject(x) {
@@ -102,6 +112,9 @@ func (r *rng) flipcoin(p uint32) bool {
 	if p > 100 {
 		p = 100
 	}
+	if r.dfs != nil {
+		return r.dfs.flipcoin(p)
+	}
 	raw := r.next31()
 	v := raw % 100
 	ok := v < p
@@ -0,0 +1,85 @@
+package csmith
+
+import (
+	"embed"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"csmith/pkg/csmith/cast"
+)
+
+// runtimeFS holds the runtime generated programs are compiled against, so
+// --runtime can make them compile without a Csmith installation.
+//
+//go:embed runtime/*.h
+var runtimeFS embed.FS
+
+// Runtime modes accepted by --runtime.
+const (
+	// runtimeInclude includes csmith.h from the compiler's include path.
+	runtimeInclude = "include"
+	// runtimeInline copies the runtime into the program.
+	runtimeInline = "inline"
+	// runtimeWrite writes the runtime headers next to the program.
+	runtimeWrite = "write"
+)
+
+var runtimeModes = []string{runtimeInclude, runtimeInline, runtimeWrite}
+
+// runtimeHeaders are the headers of the runtime; csmith.h includes the others.
+var runtimeHeaders = []string{"csmith.h", "platform_generic.h", "safe_math.h"}
+
+func validRuntimeMode(mode string) error {
+	for _, m := range runtimeModes {
+		if mode == m {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown runtime mode %q (want one of %s)", mode, strings.Join(runtimeModes, ","))
+}
+
+func runtimeHeader(name string) string {
+	b, err := runtimeFS.ReadFile("runtime/" + name)
+	if err != nil {
+		panic(fmt.Sprintf("csmith: missing runtime header %s", name))
+	}
+	return string(b)
+}
+
+// flattenRuntimeHeader returns header name with each quoted include of another
+// runtime header replaced by that header's text.
+func flattenRuntimeHeader(name string) string {
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(runtimeHeader(name), "\n") {
+		if inc, ok := strings.CutPrefix(strings.TrimSpace(line), `#include "`); ok {
+			b.WriteString(flattenRuntimeHeader(strings.TrimSuffix(inc, `"`)))
+			continue
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// runtimeDecl makes the runtime available to a generated source: an include
+// of csmith.h or, with --runtime=inline, the runtime itself.
+func runtimeDecl(opts Options) cast.Decl {
+	if opts.Runtime == runtimeInline {
+		return &cast.Verbatim{Text: flattenRuntimeHeader("csmith.h")}
+	}
+	return &cast.Include{Path: "csmith.h"}
+}
+
+// runtimeOutputs returns the runtime headers to write beside the generated
+// sources under --runtime=write, where their quoted includes find them.
+func runtimeOutputs(opts Options) []outputFile {
+	if opts.Runtime != runtimeWrite {
+		return nil
+	}
+	dir := sourceDir(opts)
+	out := make([]outputFile, 0, len(runtimeHeaders))
+	for _, name := range runtimeHeaders {
+		out = append(out, outputFile{path: filepath.Join(dir, name), content: runtimeHeader(name)})
+	}
+	return out
+}
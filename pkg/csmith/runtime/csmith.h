@@ -0,0 +1,77 @@
+#ifndef RANDOM_RUNTIME_H
+#define RANDOM_RUNTIME_H
+
+/*
+ * Runtime support for programs generated by csmith: the checksum that
+ * summarizes the final state of the globals, and the safe-math wrappers.
+ */
+
+#if defined(__cplusplus) && !defined(__STDC_LIMIT_MACROS)
+#define __STDC_LIMIT_MACROS
+#endif
+
+#include <limits.h>
+#include <stdint.h>
+#include <stdio.h>
+#include <string.h>
+
+#include "platform_generic.h"
+#include "safe_math.h"
+
+static uint32_t crc32_tab[256];
+static uint32_t crc32_context = 0xFFFFFFFFUL;
+
+static void crc32_gentab(void)
+{
+	const uint32_t poly = 0xEDB88320UL;
+	uint32_t crc;
+	int i, j;
+
+	for (i = 0; i < 256; i++) {
+		crc = (uint32_t)i;
+		for (j = 8; j > 0; j--) {
+			if (crc & 1) {
+				crc = (crc >> 1) ^ poly;
+			} else {
+				crc >>= 1;
+			}
+		}
+		crc32_tab[i] = crc;
+	}
+}
+
+static void crc32_byte(uint8_t b)
+{
+	crc32_context = ((crc32_context >> 8) & 0x00FFFFFF) ^ crc32_tab[(crc32_context ^ b) & 0xFF];
+}
+
+static void crc32_8bytes(uint64_t val)
+{
+	int i;
+
+	for (i = 0; i < 64; i += 8) {
+		crc32_byte((uint8_t)((val >> i) & 0xff));
+	}
+}
+
+static void transparent_crc(uint64_t val, const char *vname, int flag)
+{
+	crc32_8bytes(val);
+	if (flag) {
+		printf("...checksum after hashing %s : %X\n", vname, (unsigned int)(crc32_context ^ 0xFFFFFFFFU));
+	}
+}
+
+static void transparent_crc_bytes(const char *ptr, int nbytes, const char *vname, int flag)
+{
+	int i;
+
+	for (i = 0; i < nbytes; i++) {
+		crc32_byte((uint8_t)ptr[i]);
+	}
+	if (flag) {
+		printf("...checksum after hashing %s : %X\n", vname, (unsigned int)(crc32_context ^ 0xFFFFFFFFU));
+	}
+}
+
+#endif
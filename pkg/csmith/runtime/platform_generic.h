@@ -0,0 +1,17 @@
+#ifndef PLATFORM_GENERIC_H
+#define PLATFORM_GENERIC_H
+
+#include <stdint.h>
+#include <stdio.h>
+
+static void platform_main_begin(void)
+{
+}
+
+static void platform_main_end(uint32_t crc, int flag)
+{
+	(void)flag;
+	printf("checksum = %X\n", (unsigned int)crc);
+}
+
+#endif
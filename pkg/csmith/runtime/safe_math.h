@@ -0,0 +1,615 @@
+#ifndef SAFE_MATH_H
+#define SAFE_MATH_H
+
+/*
+ * Wrappers for every arithmetic operator on every integer and floating-point
+ * type a generated program uses. Each one returns its first operand instead
+ * of evaluating an operation whose result would be undefined (signed
+ * overflow, division by zero, out-of-range shifts, floating-point overflow
+ * and out-of-range conversions), so generated programs stay well defined.
+ *
+ * The floating-point bounds are built with ldexp rather than hexadecimal
+ * floating literals, which C++ only accepts from C++17 on.
+ */
+
+#include <float.h>
+#include <math.h>
+#include <stdio.h>
+
+/*
+ * Programs generated with --identify-wrappers define SAFE_MATH_SITES, the
+ * number of wrapper call sites, and call every wrapper through one of their
+ * own that first stores its site ID in safe_math_site. The wrappers then
+ * count, per site, the operations they had to guard, and
+ * safe_math_report() prints the sites that guarded any.
+ */
+#ifdef SAFE_MATH_SITES
+extern int safe_math_site;
+extern unsigned long safe_math_guarded[SAFE_MATH_SITES + 1];
+
+#define SAFE_MATH_GUARD(x) (safe_math_guarded[safe_math_site]++, (x))
+
+static void safe_math_report(void)
+{
+	int i;
+
+	for (i = 1; i <= SAFE_MATH_SITES; i++) {
+		if (safe_math_guarded[i] != 0) {
+			printf("...safe math site %d guarded %lu operations\n", i, safe_math_guarded[i]);
+		}
+	}
+}
+#else
+#define SAFE_MATH_GUARD(x) (x)
+#endif
+
+static int8_t safe_unary_minus_func_int8_t_s(int8_t si)
+{
+	return (si == INT8_MIN) ? SAFE_MATH_GUARD(si) : -si;
+}
+
+static int8_t safe_add_func_int8_t_s_s(int8_t si1, int8_t si2)
+{
+	return (((si1 > 0) && (si2 > 0) && (si1 > (INT8_MAX - si2))) || ((si1 < 0) && (si2 < 0) && (si1 < (INT8_MIN - si2)))) ? SAFE_MATH_GUARD(si1) : (si1 + si2);
+}
+
+static int8_t safe_sub_func_int8_t_s_s(int8_t si1, int8_t si2)
+{
+	return (((si2 > 0) && (si1 < (INT8_MIN + si2))) || ((si2 < 0) && (si1 > (INT8_MAX + si2)))) ? SAFE_MATH_GUARD(si1) : (si1 - si2);
+}
+
+static int8_t safe_mul_func_int8_t_s_s(int8_t si1, int8_t si2)
+{
+	return (((si1 > 0) && (si2 > 0) && (si1 > (INT8_MAX / si2))) || ((si1 > 0) && (si2 <= 0) && (si2 < (INT8_MIN / si1))) || ((si1 <= 0) && (si2 > 0) && (si1 < (INT8_MIN / si2))) || ((si1 <= 0) && (si2 <= 0) && (si1 != 0) && (si2 < (INT8_MAX / si1)))) ? SAFE_MATH_GUARD(si1) : (si1 * si2);
+}
+
+static int8_t safe_mod_func_int8_t_s_s(int8_t si1, int8_t si2)
+{
+	return ((si2 == 0) || ((si1 == INT8_MIN) && (si2 == (-1)))) ? SAFE_MATH_GUARD(si1) : (si1 % si2);
+}
+
+static int8_t safe_div_func_int8_t_s_s(int8_t si1, int8_t si2)
+{
+	return ((si2 == 0) || ((si1 == INT8_MIN) && (si2 == (-1)))) ? SAFE_MATH_GUARD(si1) : (si1 / si2);
+}
+
+static int8_t safe_lshift_func_int8_t_s_s(int8_t left, int right)
+{
+	return ((left < 0) || (right < 0) || (right >= 8) || (left > (INT8_MAX >> right))) ? SAFE_MATH_GUARD(left) : (int8_t)(left << right);
+}
+
+static int8_t safe_lshift_func_int8_t_s_u(int8_t left, unsigned int right)
+{
+	return ((left < 0) || (right >= 8u) || (left > (INT8_MAX >> right))) ? SAFE_MATH_GUARD(left) : (int8_t)(left << right);
+}
+
+static int8_t safe_rshift_func_int8_t_s_s(int8_t left, int right)
+{
+	return ((left < 0) || (right < 0) || (right >= 8)) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static int8_t safe_rshift_func_int8_t_s_u(int8_t left, unsigned int right)
+{
+	return ((left < 0) || (right >= 8u)) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static int16_t safe_unary_minus_func_int16_t_s(int16_t si)
+{
+	return (si == INT16_MIN) ? SAFE_MATH_GUARD(si) : -si;
+}
+
+static int16_t safe_add_func_int16_t_s_s(int16_t si1, int16_t si2)
+{
+	return (((si1 > 0) && (si2 > 0) && (si1 > (INT16_MAX - si2))) || ((si1 < 0) && (si2 < 0) && (si1 < (INT16_MIN - si2)))) ? SAFE_MATH_GUARD(si1) : (si1 + si2);
+}
+
+static int16_t safe_sub_func_int16_t_s_s(int16_t si1, int16_t si2)
+{
+	return (((si2 > 0) && (si1 < (INT16_MIN + si2))) || ((si2 < 0) && (si1 > (INT16_MAX + si2)))) ? SAFE_MATH_GUARD(si1) : (si1 - si2);
+}
+
+static int16_t safe_mul_func_int16_t_s_s(int16_t si1, int16_t si2)
+{
+	return (((si1 > 0) && (si2 > 0) && (si1 > (INT16_MAX / si2))) || ((si1 > 0) && (si2 <= 0) && (si2 < (INT16_MIN / si1))) || ((si1 <= 0) && (si2 > 0) && (si1 < (INT16_MIN / si2))) || ((si1 <= 0) && (si2 <= 0) && (si1 != 0) && (si2 < (INT16_MAX / si1)))) ? SAFE_MATH_GUARD(si1) : (si1 * si2);
+}
+
+static int16_t safe_mod_func_int16_t_s_s(int16_t si1, int16_t si2)
+{
+	return ((si2 == 0) || ((si1 == INT16_MIN) && (si2 == (-1)))) ? SAFE_MATH_GUARD(si1) : (si1 % si2);
+}
+
+static int16_t safe_div_func_int16_t_s_s(int16_t si1, int16_t si2)
+{
+	return ((si2 == 0) || ((si1 == INT16_MIN) && (si2 == (-1)))) ? SAFE_MATH_GUARD(si1) : (si1 / si2);
+}
+
+static int16_t safe_lshift_func_int16_t_s_s(int16_t left, int right)
+{
+	return ((left < 0) || (right < 0) || (right >= 16) || (left > (INT16_MAX >> right))) ? SAFE_MATH_GUARD(left) : (int16_t)(left << right);
+}
+
+static int16_t safe_lshift_func_int16_t_s_u(int16_t left, unsigned int right)
+{
+	return ((left < 0) || (right >= 16u) || (left > (INT16_MAX >> right))) ? SAFE_MATH_GUARD(left) : (int16_t)(left << right);
+}
+
+static int16_t safe_rshift_func_int16_t_s_s(int16_t left, int right)
+{
+	return ((left < 0) || (right < 0) || (right >= 16)) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static int16_t safe_rshift_func_int16_t_s_u(int16_t left, unsigned int right)
+{
+	return ((left < 0) || (right >= 16u)) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static int32_t safe_unary_minus_func_int32_t_s(int32_t si)
+{
+	return (si == INT32_MIN) ? SAFE_MATH_GUARD(si) : -si;
+}
+
+static int32_t safe_add_func_int32_t_s_s(int32_t si1, int32_t si2)
+{
+	return (((si1 > 0) && (si2 > 0) && (si1 > (INT32_MAX - si2))) || ((si1 < 0) && (si2 < 0) && (si1 < (INT32_MIN - si2)))) ? SAFE_MATH_GUARD(si1) : (si1 + si2);
+}
+
+static int32_t safe_sub_func_int32_t_s_s(int32_t si1, int32_t si2)
+{
+	return (((si2 > 0) && (si1 < (INT32_MIN + si2))) || ((si2 < 0) && (si1 > (INT32_MAX + si2)))) ? SAFE_MATH_GUARD(si1) : (si1 - si2);
+}
+
+static int32_t safe_mul_func_int32_t_s_s(int32_t si1, int32_t si2)
+{
+	return (((si1 > 0) && (si2 > 0) && (si1 > (INT32_MAX / si2))) || ((si1 > 0) && (si2 <= 0) && (si2 < (INT32_MIN / si1))) || ((si1 <= 0) && (si2 > 0) && (si1 < (INT32_MIN / si2))) || ((si1 <= 0) && (si2 <= 0) && (si1 != 0) && (si2 < (INT32_MAX / si1)))) ? SAFE_MATH_GUARD(si1) : (si1 * si2);
+}
+
+static int32_t safe_mod_func_int32_t_s_s(int32_t si1, int32_t si2)
+{
+	return ((si2 == 0) || ((si1 == INT32_MIN) && (si2 == (-1)))) ? SAFE_MATH_GUARD(si1) : (si1 % si2);
+}
+
+static int32_t safe_div_func_int32_t_s_s(int32_t si1, int32_t si2)
+{
+	return ((si2 == 0) || ((si1 == INT32_MIN) && (si2 == (-1)))) ? SAFE_MATH_GUARD(si1) : (si1 / si2);
+}
+
+static int32_t safe_lshift_func_int32_t_s_s(int32_t left, int right)
+{
+	return ((left < 0) || (right < 0) || (right >= 32) || (left > (INT32_MAX >> right))) ? SAFE_MATH_GUARD(left) : (int32_t)(left << right);
+}
+
+static int32_t safe_lshift_func_int32_t_s_u(int32_t left, unsigned int right)
+{
+	return ((left < 0) || (right >= 32u) || (left > (INT32_MAX >> right))) ? SAFE_MATH_GUARD(left) : (int32_t)(left << right);
+}
+
+static int32_t safe_rshift_func_int32_t_s_s(int32_t left, int right)
+{
+	return ((left < 0) || (right < 0) || (right >= 32)) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static int32_t safe_rshift_func_int32_t_s_u(int32_t left, unsigned int right)
+{
+	return ((left < 0) || (right >= 32u)) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static int64_t safe_unary_minus_func_int64_t_s(int64_t si)
+{
+	return (si == INT64_MIN) ? SAFE_MATH_GUARD(si) : -si;
+}
+
+static int64_t safe_add_func_int64_t_s_s(int64_t si1, int64_t si2)
+{
+	return (((si1 > 0) && (si2 > 0) && (si1 > (INT64_MAX - si2))) || ((si1 < 0) && (si2 < 0) && (si1 < (INT64_MIN - si2)))) ? SAFE_MATH_GUARD(si1) : (si1 + si2);
+}
+
+static int64_t safe_sub_func_int64_t_s_s(int64_t si1, int64_t si2)
+{
+	return (((si2 > 0) && (si1 < (INT64_MIN + si2))) || ((si2 < 0) && (si1 > (INT64_MAX + si2)))) ? SAFE_MATH_GUARD(si1) : (si1 - si2);
+}
+
+static int64_t safe_mul_func_int64_t_s_s(int64_t si1, int64_t si2)
+{
+	return (((si1 > 0) && (si2 > 0) && (si1 > (INT64_MAX / si2))) || ((si1 > 0) && (si2 <= 0) && (si2 < (INT64_MIN / si1))) || ((si1 <= 0) && (si2 > 0) && (si1 < (INT64_MIN / si2))) || ((si1 <= 0) && (si2 <= 0) && (si1 != 0) && (si2 < (INT64_MAX / si1)))) ? SAFE_MATH_GUARD(si1) : (si1 * si2);
+}
+
+static int64_t safe_mod_func_int64_t_s_s(int64_t si1, int64_t si2)
+{
+	return ((si2 == 0) || ((si1 == INT64_MIN) && (si2 == (-1)))) ? SAFE_MATH_GUARD(si1) : (si1 % si2);
+}
+
+static int64_t safe_div_func_int64_t_s_s(int64_t si1, int64_t si2)
+{
+	return ((si2 == 0) || ((si1 == INT64_MIN) && (si2 == (-1)))) ? SAFE_MATH_GUARD(si1) : (si1 / si2);
+}
+
+static int64_t safe_lshift_func_int64_t_s_s(int64_t left, int right)
+{
+	return ((left < 0) || (right < 0) || (right >= 64) || (left > (INT64_MAX >> right))) ? SAFE_MATH_GUARD(left) : (int64_t)(left << right);
+}
+
+static int64_t safe_lshift_func_int64_t_s_u(int64_t left, unsigned int right)
+{
+	return ((left < 0) || (right >= 64u) || (left > (INT64_MAX >> right))) ? SAFE_MATH_GUARD(left) : (int64_t)(left << right);
+}
+
+static int64_t safe_rshift_func_int64_t_s_s(int64_t left, int right)
+{
+	return ((left < 0) || (right < 0) || (right >= 64)) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static int64_t safe_rshift_func_int64_t_s_u(int64_t left, unsigned int right)
+{
+	return ((left < 0) || (right >= 64u)) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static uint8_t safe_unary_minus_func_uint8_t_u(uint8_t ui)
+{
+	return (uint8_t)(0u - (unsigned int)ui);
+}
+
+static uint8_t safe_add_func_uint8_t_u_u(uint8_t ui1, uint8_t ui2)
+{
+	return (uint8_t)((unsigned int)ui1 + (unsigned int)ui2);
+}
+
+static uint8_t safe_sub_func_uint8_t_u_u(uint8_t ui1, uint8_t ui2)
+{
+	return (uint8_t)((unsigned int)ui1 - (unsigned int)ui2);
+}
+
+static uint8_t safe_mul_func_uint8_t_u_u(uint8_t ui1, uint8_t ui2)
+{
+	return (uint8_t)((unsigned int)ui1 * (unsigned int)ui2);
+}
+
+static uint8_t safe_mod_func_uint8_t_u_u(uint8_t ui1, uint8_t ui2)
+{
+	return (ui2 == 0) ? SAFE_MATH_GUARD(ui1) : (ui1 % ui2);
+}
+
+static uint8_t safe_div_func_uint8_t_u_u(uint8_t ui1, uint8_t ui2)
+{
+	return (ui2 == 0) ? SAFE_MATH_GUARD(ui1) : (ui1 / ui2);
+}
+
+static uint8_t safe_lshift_func_uint8_t_u_s(uint8_t left, int right)
+{
+	return ((right < 0) || (right >= 8) || (left > (UINT8_MAX >> right))) ? SAFE_MATH_GUARD(left) : (uint8_t)((unsigned int)left << right);
+}
+
+static uint8_t safe_lshift_func_uint8_t_u_u(uint8_t left, unsigned int right)
+{
+	return ((right >= 8u) || (left > (UINT8_MAX >> right))) ? SAFE_MATH_GUARD(left) : (uint8_t)((unsigned int)left << right);
+}
+
+static uint8_t safe_rshift_func_uint8_t_u_s(uint8_t left, int right)
+{
+	return ((right < 0) || (right >= 8)) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static uint8_t safe_rshift_func_uint8_t_u_u(uint8_t left, unsigned int right)
+{
+	return (right >= 8u) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static uint16_t safe_unary_minus_func_uint16_t_u(uint16_t ui)
+{
+	return (uint16_t)(0u - (unsigned int)ui);
+}
+
+static uint16_t safe_add_func_uint16_t_u_u(uint16_t ui1, uint16_t ui2)
+{
+	return (uint16_t)((unsigned int)ui1 + (unsigned int)ui2);
+}
+
+static uint16_t safe_sub_func_uint16_t_u_u(uint16_t ui1, uint16_t ui2)
+{
+	return (uint16_t)((unsigned int)ui1 - (unsigned int)ui2);
+}
+
+static uint16_t safe_mul_func_uint16_t_u_u(uint16_t ui1, uint16_t ui2)
+{
+	return (uint16_t)((unsigned int)ui1 * (unsigned int)ui2);
+}
+
+static uint16_t safe_mod_func_uint16_t_u_u(uint16_t ui1, uint16_t ui2)
+{
+	return (ui2 == 0) ? SAFE_MATH_GUARD(ui1) : (ui1 % ui2);
+}
+
+static uint16_t safe_div_func_uint16_t_u_u(uint16_t ui1, uint16_t ui2)
+{
+	return (ui2 == 0) ? SAFE_MATH_GUARD(ui1) : (ui1 / ui2);
+}
+
+static uint16_t safe_lshift_func_uint16_t_u_s(uint16_t left, int right)
+{
+	return ((right < 0) || (right >= 16) || (left > (UINT16_MAX >> right))) ? SAFE_MATH_GUARD(left) : (uint16_t)((unsigned int)left << right);
+}
+
+static uint16_t safe_lshift_func_uint16_t_u_u(uint16_t left, unsigned int right)
+{
+	return ((right >= 16u) || (left > (UINT16_MAX >> right))) ? SAFE_MATH_GUARD(left) : (uint16_t)((unsigned int)left << right);
+}
+
+static uint16_t safe_rshift_func_uint16_t_u_s(uint16_t left, int right)
+{
+	return ((right < 0) || (right >= 16)) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static uint16_t safe_rshift_func_uint16_t_u_u(uint16_t left, unsigned int right)
+{
+	return (right >= 16u) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static uint32_t safe_unary_minus_func_uint32_t_u(uint32_t ui)
+{
+	return (uint32_t)(0u - (unsigned int)ui);
+}
+
+static uint32_t safe_add_func_uint32_t_u_u(uint32_t ui1, uint32_t ui2)
+{
+	return (uint32_t)((unsigned int)ui1 + (unsigned int)ui2);
+}
+
+static uint32_t safe_sub_func_uint32_t_u_u(uint32_t ui1, uint32_t ui2)
+{
+	return (uint32_t)((unsigned int)ui1 - (unsigned int)ui2);
+}
+
+static uint32_t safe_mul_func_uint32_t_u_u(uint32_t ui1, uint32_t ui2)
+{
+	return (uint32_t)((unsigned int)ui1 * (unsigned int)ui2);
+}
+
+static uint32_t safe_mod_func_uint32_t_u_u(uint32_t ui1, uint32_t ui2)
+{
+	return (ui2 == 0) ? SAFE_MATH_GUARD(ui1) : (ui1 % ui2);
+}
+
+static uint32_t safe_div_func_uint32_t_u_u(uint32_t ui1, uint32_t ui2)
+{
+	return (ui2 == 0) ? SAFE_MATH_GUARD(ui1) : (ui1 / ui2);
+}
+
+static uint32_t safe_lshift_func_uint32_t_u_s(uint32_t left, int right)
+{
+	return ((right < 0) || (right >= 32) || (left > (UINT32_MAX >> right))) ? SAFE_MATH_GUARD(left) : (uint32_t)((unsigned int)left << right);
+}
+
+static uint32_t safe_lshift_func_uint32_t_u_u(uint32_t left, unsigned int right)
+{
+	return ((right >= 32u) || (left > (UINT32_MAX >> right))) ? SAFE_MATH_GUARD(left) : (uint32_t)((unsigned int)left << right);
+}
+
+static uint32_t safe_rshift_func_uint32_t_u_s(uint32_t left, int right)
+{
+	return ((right < 0) || (right >= 32)) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static uint32_t safe_rshift_func_uint32_t_u_u(uint32_t left, unsigned int right)
+{
+	return (right >= 32u) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static uint64_t safe_unary_minus_func_uint64_t_u(uint64_t ui)
+{
+	return (uint64_t)(0u - (uint64_t)ui);
+}
+
+static uint64_t safe_add_func_uint64_t_u_u(uint64_t ui1, uint64_t ui2)
+{
+	return (uint64_t)((uint64_t)ui1 + (uint64_t)ui2);
+}
+
+static uint64_t safe_sub_func_uint64_t_u_u(uint64_t ui1, uint64_t ui2)
+{
+	return (uint64_t)((uint64_t)ui1 - (uint64_t)ui2);
+}
+
+static uint64_t safe_mul_func_uint64_t_u_u(uint64_t ui1, uint64_t ui2)
+{
+	return (uint64_t)((uint64_t)ui1 * (uint64_t)ui2);
+}
+
+static uint64_t safe_mod_func_uint64_t_u_u(uint64_t ui1, uint64_t ui2)
+{
+	return (ui2 == 0) ? SAFE_MATH_GUARD(ui1) : (ui1 % ui2);
+}
+
+static uint64_t safe_div_func_uint64_t_u_u(uint64_t ui1, uint64_t ui2)
+{
+	return (ui2 == 0) ? SAFE_MATH_GUARD(ui1) : (ui1 / ui2);
+}
+
+static uint64_t safe_lshift_func_uint64_t_u_s(uint64_t left, int right)
+{
+	return ((right < 0) || (right >= 64) || (left > (UINT64_MAX >> right))) ? SAFE_MATH_GUARD(left) : (uint64_t)((uint64_t)left << right);
+}
+
+static uint64_t safe_lshift_func_uint64_t_u_u(uint64_t left, unsigned int right)
+{
+	return ((right >= 64u) || (left > (UINT64_MAX >> right))) ? SAFE_MATH_GUARD(left) : (uint64_t)((uint64_t)left << right);
+}
+
+static uint64_t safe_rshift_func_uint64_t_u_s(uint64_t left, int right)
+{
+	return ((right < 0) || (right >= 64)) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static uint64_t safe_rshift_func_uint64_t_u_u(uint64_t left, unsigned int right)
+{
+	return (right >= 64u) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+#if defined(__SIZEOF_INT128__)
+typedef __int128 int128_t;
+typedef unsigned __int128 uint128_t;
+#define UINT128_MAX (~(uint128_t)0)
+#define INT128_MAX ((int128_t)(UINT128_MAX >> 1))
+#define INT128_MIN (-INT128_MAX - 1)
+
+static int128_t safe_unary_minus_func_int128_t_s(int128_t si)
+{
+	return (si == INT128_MIN) ? SAFE_MATH_GUARD(si) : -si;
+}
+
+static int128_t safe_add_func_int128_t_s_s(int128_t si1, int128_t si2)
+{
+	return (((si1 > 0) && (si2 > 0) && (si1 > (INT128_MAX - si2))) || ((si1 < 0) && (si2 < 0) && (si1 < (INT128_MIN - si2)))) ? SAFE_MATH_GUARD(si1) : (si1 + si2);
+}
+
+static int128_t safe_sub_func_int128_t_s_s(int128_t si1, int128_t si2)
+{
+	return (((si2 > 0) && (si1 < (INT128_MIN + si2))) || ((si2 < 0) && (si1 > (INT128_MAX + si2)))) ? SAFE_MATH_GUARD(si1) : (si1 - si2);
+}
+
+static int128_t safe_mul_func_int128_t_s_s(int128_t si1, int128_t si2)
+{
+	return (((si1 > 0) && (si2 > 0) && (si1 > (INT128_MAX / si2))) || ((si1 > 0) && (si2 <= 0) && (si2 < (INT128_MIN / si1))) || ((si1 <= 0) && (si2 > 0) && (si1 < (INT128_MIN / si2))) || ((si1 <= 0) && (si2 <= 0) && (si1 != 0) && (si2 < (INT128_MAX / si1)))) ? SAFE_MATH_GUARD(si1) : (si1 * si2);
+}
+
+static int128_t safe_mod_func_int128_t_s_s(int128_t si1, int128_t si2)
+{
+	return ((si2 == 0) || ((si1 == INT128_MIN) && (si2 == (-1)))) ? SAFE_MATH_GUARD(si1) : (si1 % si2);
+}
+
+static int128_t safe_div_func_int128_t_s_s(int128_t si1, int128_t si2)
+{
+	return ((si2 == 0) || ((si1 == INT128_MIN) && (si2 == (-1)))) ? SAFE_MATH_GUARD(si1) : (si1 / si2);
+}
+
+static int128_t safe_lshift_func_int128_t_s_s(int128_t left, int right)
+{
+	return ((left < 0) || (right < 0) || (right >= 128) || (left > (INT128_MAX >> right))) ? SAFE_MATH_GUARD(left) : (int128_t)(left << right);
+}
+
+static int128_t safe_lshift_func_int128_t_s_u(int128_t left, unsigned int right)
+{
+	return ((left < 0) || (right >= 128u) || (left > (INT128_MAX >> right))) ? SAFE_MATH_GUARD(left) : (int128_t)(left << right);
+}
+
+static int128_t safe_rshift_func_int128_t_s_s(int128_t left, int right)
+{
+	return ((left < 0) || (right < 0) || (right >= 128)) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static int128_t safe_rshift_func_int128_t_s_u(int128_t left, unsigned int right)
+{
+	return ((left < 0) || (right >= 128u)) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static uint128_t safe_unary_minus_func_uint128_t_u(uint128_t ui)
+{
+	return (uint128_t)(0u - (uint128_t)ui);
+}
+
+static uint128_t safe_add_func_uint128_t_u_u(uint128_t ui1, uint128_t ui2)
+{
+	return (uint128_t)((uint128_t)ui1 + (uint128_t)ui2);
+}
+
+static uint128_t safe_sub_func_uint128_t_u_u(uint128_t ui1, uint128_t ui2)
+{
+	return (uint128_t)((uint128_t)ui1 - (uint128_t)ui2);
+}
+
+static uint128_t safe_mul_func_uint128_t_u_u(uint128_t ui1, uint128_t ui2)
+{
+	return (uint128_t)((uint128_t)ui1 * (uint128_t)ui2);
+}
+
+static uint128_t safe_mod_func_uint128_t_u_u(uint128_t ui1, uint128_t ui2)
+{
+	return (ui2 == 0) ? SAFE_MATH_GUARD(ui1) : (ui1 % ui2);
+}
+
+static uint128_t safe_div_func_uint128_t_u_u(uint128_t ui1, uint128_t ui2)
+{
+	return (ui2 == 0) ? SAFE_MATH_GUARD(ui1) : (ui1 / ui2);
+}
+
+static uint128_t safe_lshift_func_uint128_t_u_s(uint128_t left, int right)
+{
+	return ((right < 0) || (right >= 128) || (left > (UINT128_MAX >> right))) ? SAFE_MATH_GUARD(left) : (uint128_t)((uint128_t)left << right);
+}
+
+static uint128_t safe_lshift_func_uint128_t_u_u(uint128_t left, unsigned int right)
+{
+	return ((right >= 128u) || (left > (UINT128_MAX >> right))) ? SAFE_MATH_GUARD(left) : (uint128_t)((uint128_t)left << right);
+}
+
+static uint128_t safe_rshift_func_uint128_t_u_s(uint128_t left, int right)
+{
+	return ((right < 0) || (right >= 128)) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+static uint128_t safe_rshift_func_uint128_t_u_u(uint128_t left, unsigned int right)
+{
+	return (right >= 128u) ? SAFE_MATH_GUARD(left) : (left >> right);
+}
+
+#endif
+
+static float safe_unary_minus_func_float_f(float sf)
+{
+	return -sf;
+}
+
+static float safe_add_func_float_f_f(float sf1, float sf2)
+{
+	return (fabsf((0.5f * sf1) + (0.5f * sf2)) > (0.5f * FLT_MAX)) ? SAFE_MATH_GUARD(sf1) : (sf1 + sf2);
+}
+
+static float safe_sub_func_float_f_f(float sf1, float sf2)
+{
+	return (fabsf((0.5f * sf1) - (0.5f * sf2)) > (0.5f * FLT_MAX)) ? SAFE_MATH_GUARD(sf1) : (sf1 - sf2);
+}
+
+static float safe_mul_func_float_f_f(float sf1, float sf2)
+{
+	return (fabsf((ldexpf(1.0f, -100) * sf1) * (ldexpf(1.0f, -28) * sf2)) > (ldexpf(1.0f, -100) * (ldexpf(1.0f, -28) * FLT_MAX))) ? SAFE_MATH_GUARD(sf1) : (sf1 * sf2);
+}
+
+static float safe_div_func_float_f_f(float sf1, float sf2)
+{
+	return ((fabsf(sf2) < 1.0f) && ((sf2 == 0.0f) || (fabsf((ldexpf(1.0f, -49) * sf1) / (ldexpf(1.0f, 100) * sf2)) > (ldexpf(1.0f, -100) * (ldexpf(1.0f, -49) * FLT_MAX))))) ? SAFE_MATH_GUARD(sf1) : (sf1 / sf2);
+}
+
+static int32_t safe_convert_func_float_to_int32_t(float sf)
+{
+	return ((sf != sf) || (sf <= INT32_MIN) || (sf >= INT32_MAX)) ? SAFE_MATH_GUARD(INT32_MAX) : (int32_t)sf;
+}
+
+static double safe_unary_minus_func_double_f(double sf)
+{
+	return -sf;
+}
+
+static double safe_add_func_double_f_f(double sf1, double sf2)
+{
+	return (fabs((0.5 * sf1) + (0.5 * sf2)) > (0.5 * DBL_MAX)) ? SAFE_MATH_GUARD(sf1) : (sf1 + sf2);
+}
+
+static double safe_sub_func_double_f_f(double sf1, double sf2)
+{
+	return (fabs((0.5 * sf1) - (0.5 * sf2)) > (0.5 * DBL_MAX)) ? SAFE_MATH_GUARD(sf1) : (sf1 - sf2);
+}
+
+static double safe_mul_func_double_f_f(double sf1, double sf2)
+{
+	return (fabs((ldexp(1.0, -100) * sf1) * (ldexp(1.0, -924) * sf2)) > (ldexp(1.0, -100) * (ldexp(1.0, -924) * DBL_MAX))) ? SAFE_MATH_GUARD(sf1) : (sf1 * sf2);
+}
+
+static double safe_div_func_double_f_f(double sf1, double sf2)
+{
+	return ((fabs(sf2) < 1.0) && ((sf2 == 0.0) || (fabs((ldexp(1.0, -974) * sf1) / (ldexp(1.0, 100) * sf2)) > (ldexp(1.0, -100) * (ldexp(1.0, -974) * DBL_MAX))))) ? SAFE_MATH_GUARD(sf1) : (sf1 / sf2);
+}
+
+static int32_t safe_convert_func_double_to_int32_t(double sf)
+{
+	return ((sf != sf) || (sf <= INT32_MIN) || (sf >= INT32_MAX)) ? SAFE_MATH_GUARD(INT32_MAX) : (int32_t)sf;
+}
+
+#endif
@@ -0,0 +1,433 @@
+package csmith
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"csmith/pkg/csmith/cast"
+)
+
+// binaryOps lists the binary operators in upstream eBinaryOps order, so the
+// operator draw of a binary expression indexes it.
+var binaryOps = [...]string{"+", "-", "*", "/", "%", ">", "<", ">=", "<=", "==", "!=", "&&", "||", "^", "&", "|", ">>", "<<"}
+
+// unaryOps lists the unary operators in upstream eUnaryOps order.
+var unaryOps = [...]string{"+", "-", "!", "~"}
+
+// safeOpNames maps the operators with a safe-math wrapper to the name the
+// wrapper carries.
+var safeOpNames = map[string]string{
+	"+":  "add",
+	"-":  "sub",
+	"*":  "mul",
+	"/":  "div",
+	"%":  "mod",
+	"<<": "lshift",
+	">>": "rshift",
+}
+
+// safeTypeName names t in wrapper names: the float type itself, or the
+// fixed-width integer type of the same size and signedness.
+func safeTypeName(t CType) string {
+	if t.Float {
+		return t.Name
+	}
+	bits := t.Bits
+	if bits != 8 && bits != 16 && bits != 32 && bits != 64 && bits != 128 {
+		bits = 32
+	}
+	if t.Signed {
+		return fmt.Sprintf("int%d_t", bits)
+	}
+	return fmt.Sprintf("uint%d_t", bits)
+}
+
+// safeOperandKind is the per-operand suffix of wrapper names: "s", "u" or "f".
+func safeOperandKind(t CType) string {
+	switch {
+	case t.Float:
+		return "f"
+	case t.Signed:
+		return "s"
+	}
+	return "u"
+}
+
+// binaryOpSupported reports whether op applies to operands of type t and is
+// enabled by the options; --no-muls and --no-divs remove the operators
+// upstream filters out of its draw.
+func binaryOpSupported(op string, t CType, opts Options) bool {
+	switch op {
+	case "*":
+		return opts.Muls
+	case "/":
+		return opts.Divs
+	case "%":
+		return opts.Divs && !t.Float
+	case "^", "&", "|", "<<", ">>":
+		return !t.Float
+	}
+	return true
+}
+
+// safeArithExpr applies the arithmetic operator op ("+", "-", "*", "/" or
+// "%") to a and b, both of type t, through its safe-math wrapper.
+func safeArithExpr(op string, t CType, a, b cast.Expr, opts Options) cast.Expr {
+	if !opts.SafeMath {
+		return paren(binary(paren(a), op, paren(b)))
+	}
+	k := safeOperandKind(t)
+	return call(fmt.Sprintf("safe_%s_func_%s_%s_%s", safeOpNames[op], safeTypeName(t), k, k), a, b)
+}
+
+// safeShiftExpr shifts a of type t by b of type bt through the safe-math
+// wrapper for op ("<<" or ">>").
+func safeShiftExpr(op string, t CType, a cast.Expr, bt CType, b cast.Expr, opts Options) cast.Expr {
+	if !opts.SafeMath {
+		return paren(binary(paren(a), op, paren(b)))
+	}
+	return call(fmt.Sprintf("safe_%s_func_%s_%s_%s", safeOpNames[op], safeTypeName(t), safeOperandKind(t), safeOperandKind(bt)), a, b)
+}
+
+// safeUnaryMinusExpr negates x of type t through its safe-math wrapper.
+func safeUnaryMinusExpr(t CType, x cast.Expr, opts Options) cast.Expr {
+	if !opts.SafeMath {
+		return paren(&cast.Unary{Op: "-", X: paren(x)})
+	}
+	return call(fmt.Sprintf("safe_unary_minus_func_%s_%s", safeTypeName(t), safeOperandKind(t)), x)
+}
+
+func safeAddExpr(t CType, a, b cast.Expr, opts Options) cast.Expr {
+	return safeArithExpr("+", t, a, b, opts)
+}
+
+// parseSafeMathWrappers splits the comma-separated --safe-math-wrappers list
+// of wrapper IDs. IDs count from 1; 0 selects no site, as an empty list
+// selects them all.
+func parseSafeMathWrappers(list string) (map[int]bool, error) {
+	ids := map[int]bool{}
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		id, err := strconv.Atoi(s)
+		if err != nil || id < 0 {
+			return nil, fmt.Errorf("invalid safe math wrapper id %q", s)
+		}
+		ids[id] = true
+	}
+	return ids, nil
+}
+
+// safeWrapperCall describes a call to a safe-math wrapper.
+type safeWrapperCall struct {
+	call *cast.Call
+	// op is the operator the wrapper guards; "-" with a single argument is
+	// unary minus, and "(T)" a conversion to int32_t.
+	op string
+	// ctype is the result type and params the parameter types.
+	ctype  CType
+	params []CType
+}
+
+// asSafeWrapperCall recognizes e as a call to a safe-math wrapper.
+func asSafeWrapperCall(e cast.Expr) (safeWrapperCall, bool) {
+	c, ok := e.(*cast.Call)
+	if !ok {
+		return safeWrapperCall{}, false
+	}
+	id, ok := c.Fun.(*cast.Ident)
+	if !ok {
+		return safeWrapperCall{}, false
+	}
+	rest, ok := strings.CutPrefix(id.Name, "safe_")
+	if !ok {
+		return safeWrapperCall{}, false
+	}
+	if from, ok := strings.CutPrefix(rest, "convert_func_"); ok && strings.HasSuffix(from, "_to_int32_t") {
+		src := CType{Name: strings.TrimSuffix(from, "_to_int32_t"), Signed: true, Float: true}
+		return safeWrapperCall{call: c, op: "(T)", ctype: CType{Name: "int32_t", Signed: true, Bits: 32}, params: []CType{src}}, true
+	}
+	name, typ, ok := strings.Cut(rest, "_func_")
+	if !ok {
+		return safeWrapperCall{}, false
+	}
+	op := ""
+	if name == "unary_minus" {
+		op = "-"
+	}
+	for sym, n := range safeOpNames {
+		if n == name {
+			op = sym
+		}
+	}
+	t, ok := safeWrapperType(typ)
+	if op == "" || !ok {
+		return safeWrapperCall{}, false
+	}
+	w := safeWrapperCall{call: c, op: op, ctype: t, params: []CType{t}}
+	switch {
+	case name == "unary_minus":
+	case op == "<<" || op == ">>":
+		// The shift count is an int, or an unsigned int after a "_u"
+		// count suffix.
+		count := CType{Name: "int", Signed: true, Bits: 32}
+		if strings.HasSuffix(typ, "_u") {
+			count = CType{Name: "unsigned int", Bits: 32}
+		}
+		w.params = append(w.params, count)
+	default:
+		w.params = append(w.params, t)
+	}
+	return w, true
+}
+
+// safeWrapperType recovers the result type from the type part of a wrapper
+// name, e.g. "int16_t_s_s".
+func safeWrapperType(s string) (CType, bool) {
+	for _, fp := range []string{"float", "double"} {
+		if strings.HasPrefix(s, fp+"_") {
+			return CType{Name: fp, Signed: true, Float: true}, true
+		}
+	}
+	signed := !strings.HasPrefix(s, "u")
+	var bits int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(s, "u"), "int%d_t", &bits); err != nil {
+		return CType{}, false
+	}
+	name := fmt.Sprintf("int%d_t", bits)
+	if !signed {
+		name = "u" + name
+	}
+	if bits == 128 {
+		name = "__int128"
+		if !signed {
+			name = "unsigned __int128"
+		}
+	}
+	return CType{Name: name, Signed: signed, Bits: bits}, true
+}
+
+// unwrapped evaluates the guarded operation directly, converted to the type
+// the wrapper returns.
+func (w safeWrapperCall) unwrapped() cast.Expr {
+	args := w.call.Args
+	switch {
+	case w.op == "(T)":
+		return castExpr(w.ctype, args[0])
+	case len(args) == 1:
+		return castExpr(w.ctype, &cast.Unary{Op: "-", X: paren(args[0])})
+	}
+	return castExpr(w.ctype, binary(paren(args[0]), w.op, paren(args[1])))
+}
+
+// numberSafeMathWrappers numbers the safe-math wrapper calls of f from 1,
+// declarations in order and each call after the wrappers in its operands, and
+// calls visit with each call, its ID and the function it is in ("" in global
+// initializers); the call is replaced by what visit returns. It returns the
+// number of calls.
+func numberSafeMathWrappers(f *cast.File, visit func(id int, fn string, w safeWrapperCall) cast.Expr) int {
+	id := 0
+	for _, d := range f.Decls {
+		var s cast.Stmt
+		fn := ""
+		switch d := d.(type) {
+		case *cast.VarDecl:
+			s = d
+		case *cast.FuncDef:
+			s, fn = d.Body, d.Name
+		default:
+			continue
+		}
+		rewriteStmtExprs(s, func(e cast.Expr) cast.Expr {
+			w, ok := asSafeWrapperCall(e)
+			if !ok {
+				return e
+			}
+			id++
+			return visit(id, fn, w)
+		})
+	}
+	return id
+}
+
+// identifiesWrappers reports whether safe-math wrapper call sites get IDs.
+func (o Options) identifiesWrappers() bool {
+	return o.SafeMath && o.IdentifyWrappers
+}
+
+// wrapperSite is one entry of the --wrapper-sites report.
+type wrapperSite struct {
+	ID       int    `json:"id"`
+	Wrapper  string `json:"wrapper"`
+	Op       string `json:"op"`
+	Type     string `json:"type"`
+	Function string `json:"function,omitempty"`
+}
+
+// resolveSafeMathWrappers settles the safe-math wrapper calls of the finished
+// program f. Only the calls whose ID --safe-math-wrappers lists are kept,
+// when it lists any; every other guarded operation is evaluated directly,
+// unguarded, so sanitizers see its undefined behaviour.
+// With --identify-wrappers each kept call goes through a wrapper of its own,
+// named after the call's ID, and the sites are returned.
+func resolveSafeMathWrappers(f *cast.File, opts Options) []wrapperSite {
+	if !opts.SafeMath {
+		return nil
+	}
+	keep, _ := parseSafeMathWrappers(opts.SafeMathWrappers)
+	var sites []wrapperSite
+	var defs []*cast.FuncDef
+	n := numberSafeMathWrappers(f, func(id int, fn string, w safeWrapperCall) cast.Expr {
+		if len(keep) > 0 && !keep[id] {
+			return w.unwrapped()
+		}
+		if !opts.identifiesWrappers() {
+			return w.call
+		}
+		name := w.call.Fun.(*cast.Ident).Name
+		sites = append(sites, wrapperSite{ID: id, Wrapper: name, Op: w.op, Type: w.ctype.Name, Function: fn})
+		defs = append(defs, w.siteWrapper(id))
+		w.call.Fun = ident(defs[len(defs)-1].Name)
+		return w.call
+	})
+	if opts.identifiesWrappers() {
+		declareWrapperSites(f, n, defs)
+	}
+	return sites
+}
+
+// siteWrapper defines the wrapper of call site id: it records the site in
+// safe_math_site, then calls the runtime wrapper.
+func (w safeWrapperCall) siteWrapper(id int) *cast.FuncDef {
+	name := w.call.Fun.(*cast.Ident).Name
+	def := &cast.FuncDef{
+		FuncDecl: cast.FuncDecl{Storage: "static", Ret: typeNode(w.ctype), Name: fmt.Sprintf("%s_%d", name, id)},
+		Body:     &cast.Block{},
+	}
+	args := make([]cast.Expr, len(w.params))
+	for i, p := range w.params {
+		param := fmt.Sprintf("p_%d", i+1)
+		def.Params = append(def.Params, &cast.Param{Type: typeNode(p), Name: param})
+		args[i] = ident(param)
+	}
+	addStmt(def.Body, exprStmt(assign(ident("safe_math_site"), "=", lit("%d", id))))
+	addStmt(def.Body, &cast.Return{X: call(name, args...)})
+	return def
+}
+
+// declareWrapperSites defines SAFE_MATH_SITES ahead of the runtime, which
+// then counts the guarded operations of each of the n sites, and adds the
+// counters and the per-site wrappers after it.
+func declareWrapperSites(f *cast.File, n int, defs []*cast.FuncDef) {
+	at := 0
+	for i, d := range f.Decls {
+		switch d := d.(type) {
+		case *cast.Include:
+			if d.Path == "csmith.h" {
+				at = i
+			}
+		case *cast.Verbatim:
+			at = i
+		}
+	}
+	decls := []cast.Decl{
+		&cast.Directive{Text: fmt.Sprintf("define SAFE_MATH_SITES %d", n)},
+		f.Decls[at],
+		&cast.Blank{},
+		&cast.VarDecl{Type: &cast.Named{Name: "int"}, Name: "safe_math_site"},
+		&cast.VarDecl{Type: &cast.Array{Elem: &cast.Named{Name: "unsigned long"}, Len: n + 1}, Name: "safe_math_guarded"},
+		&cast.Blank{},
+	}
+	for _, def := range defs {
+		proto := def.FuncDecl
+		decls = append(decls, &proto)
+	}
+	for _, def := range defs {
+		decls = append(decls, &cast.Blank{}, def)
+	}
+	rest := append(decls, f.Decls[at+1:]...)
+	f.Decls = append(f.Decls[:at], rest...)
+}
+
+// wrapperSitesReport lists the identified safe-math wrapper sites as JSON.
+func wrapperSitesReport(sites []wrapperSite) string {
+	if sites == nil {
+		sites = []wrapperSite{}
+	}
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	// Keep the operators readable.
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(sites)
+	return b.String()
+}
+
+func rewriteStmtExprs(s cast.Stmt, fn func(cast.Expr) cast.Expr) {
+	switch s := s.(type) {
+	case *cast.Block:
+		for _, st := range s.Stmts {
+			rewriteStmtExprs(st, fn)
+		}
+	case *cast.VarDecl:
+		s.Init = rewriteExpr(s.Init, fn)
+	case *cast.ExprStmt:
+		s.X = rewriteExpr(s.X, fn)
+	case *cast.If:
+		s.Cond = rewriteExpr(s.Cond, fn)
+		rewriteStmtExprs(s.Then, fn)
+		rewriteStmtExprs(s.Else, fn)
+	case *cast.For:
+		rewriteStmtExprs(s.Init, fn)
+		s.Cond = rewriteExpr(s.Cond, fn)
+		s.Post = rewriteExpr(s.Post, fn)
+		rewriteStmtExprs(s.Prologue, fn)
+		rewriteStmtExprs(s.Body, fn)
+	case *cast.Return:
+		s.X = rewriteExpr(s.X, fn)
+	case *cast.Labeled:
+		rewriteStmtExprs(s.Stmt, fn)
+	}
+}
+
+func rewriteExpr(e cast.Expr, fn func(cast.Expr) cast.Expr) cast.Expr {
+	switch x := e.(type) {
+	case nil:
+		return nil
+	case *cast.Paren:
+		x.X = rewriteExpr(x.X, fn)
+	case *cast.Cast:
+		x.X = rewriteExpr(x.X, fn)
+	case *cast.Unary:
+		x.X = rewriteExpr(x.X, fn)
+	case *cast.Postfix:
+		x.X = rewriteExpr(x.X, fn)
+	case *cast.Binary:
+		x.X = rewriteExpr(x.X, fn)
+		x.Y = rewriteExpr(x.Y, fn)
+	case *cast.Assign:
+		x.LHS = rewriteExpr(x.LHS, fn)
+		x.RHS = rewriteExpr(x.RHS, fn)
+	case *cast.Comma:
+		x.X = rewriteExpr(x.X, fn)
+		x.Y = rewriteExpr(x.Y, fn)
+	case *cast.Call:
+		for i, a := range x.Args {
+			x.Args[i] = rewriteExpr(a, fn)
+		}
+	case *cast.Index:
+		x.X = rewriteExpr(x.X, fn)
+		x.Index = rewriteExpr(x.Index, fn)
+	case *cast.Member:
+		x.X = rewriteExpr(x.X, fn)
+	case *cast.InitList:
+		for i, el := range x.Elems {
+			x.Elems[i] = rewriteExpr(el, fn)
+		}
+	}
+	return fn(e)
+}
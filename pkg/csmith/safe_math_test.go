@@ -0,0 +1,169 @@
+package csmith
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"csmith/pkg/csmith/cast"
+)
+
+func TestParseSafeMathWrappers(t *testing.T) {
+	ids, err := parseSafeMathWrappers(" 3, 1,,3 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || !ids[1] || !ids[3] {
+		t.Fatalf("parseSafeMathWrappers = %v, want {1,3}", ids)
+	}
+	if ids, err := parseSafeMathWrappers(""); err != nil || len(ids) != 0 {
+		t.Fatalf("parseSafeMathWrappers(\"\") = %v, %v; want empty", ids, err)
+	}
+	if ids, err := parseSafeMathWrappers("0"); err != nil || len(ids) != 1 || !ids[0] {
+		t.Fatalf("parseSafeMathWrappers(\"0\") = %v, %v; want {0}", ids, err)
+	}
+	for _, bad := range []string{"-1", "x", "1;2"} {
+		if _, err := parseSafeMathWrappers(bad); err == nil {
+			t.Errorf("parseSafeMathWrappers(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestAsSafeWrapperCall(t *testing.T) {
+	a, b := ident("a"), ident("b")
+	for _, tc := range []struct {
+		name   string
+		args   []cast.Expr
+		op     string
+		ctype  string
+		params []string
+	}{
+		{"safe_add_func_int8_t_s_s", []cast.Expr{a, b}, "+", "int8_t", []string{"int8_t", "int8_t"}},
+		{"safe_mod_func_uint64_t_u_u", []cast.Expr{a, b}, "%", "uint64_t", []string{"uint64_t", "uint64_t"}},
+		{"safe_lshift_func_int32_t_s_s", []cast.Expr{a, b}, "<<", "int32_t", []string{"int32_t", "int"}},
+		{"safe_rshift_func_uint16_t_u_u", []cast.Expr{a, b}, ">>", "uint16_t", []string{"uint16_t", "unsigned int"}},
+		{"safe_unary_minus_func_int16_t_s", []cast.Expr{a}, "-", "int16_t", []string{"int16_t"}},
+		{"safe_mul_func_uint128_t_u_u", []cast.Expr{a, b}, "*", "unsigned __int128", []string{"unsigned __int128", "unsigned __int128"}},
+		{"safe_div_func_double_f_f", []cast.Expr{a, b}, "/", "double", []string{"double", "double"}},
+		{"safe_convert_func_float_to_int32_t", []cast.Expr{a}, "(T)", "int32_t", []string{"float"}},
+	} {
+		w, ok := asSafeWrapperCall(call(tc.name, tc.args...))
+		if !ok {
+			t.Fatalf("%s not recognized", tc.name)
+		}
+		if w.op != tc.op || w.ctype.Name != tc.ctype || len(w.params) != len(tc.params) {
+			t.Fatalf("%s: op %q type %s params %v", tc.name, w.op, w.ctype.Name, w.params)
+		}
+		for i, p := range w.params {
+			if p.Name != tc.params[i] {
+				t.Fatalf("%s: param %d is %s, want %s", tc.name, i, p.Name, tc.params[i])
+			}
+		}
+	}
+	for _, e := range []cast.Expr{
+		call("safe_math_report"),
+		call("safe_xor_func_int8_t_s_s", a, b),
+		call("safe_add_func_char_s_s", a, b),
+		call("transparent_crc", a),
+		a,
+	} {
+		if _, ok := asSafeWrapperCall(e); ok {
+			t.Errorf("%s recognized as a safe-math wrapper", cast.Print(e))
+		}
+	}
+}
+
+func TestSafeMathWrapperSelection(t *testing.T) {
+	opts := Defaults()
+	opts.Seed = 7
+	program, err := Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := countSafeWrapperCalls(program)
+	if all < 3 {
+		t.Fatalf("seed 7 has %d safe-math calls, too few to select from", all)
+	}
+	for _, tc := range []struct {
+		list    string
+		guarded func(n int) int
+	}{
+		{"", func(n int) int { return n }},
+		{"0", func(int) int { return 0 }},
+		{"1,2", func(n int) int { return min(n, 2) }},
+	} {
+		opts.SafeMathWrappers = tc.list
+		program, err = Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := countSafeWrapperCalls(program), tc.guarded(all); got != want {
+			t.Fatalf("--safe-math-wrappers %q: %d guarded calls, want %d of %d", tc.list, got, want, all)
+		}
+	}
+}
+
+var safeWrapperCallRE = regexp.MustCompile(`\bsafe_[a-z_]+_func_[a-z0-9_]+\(`)
+
+func countSafeWrapperCalls(program string) int {
+	return len(safeWrapperCallRE.FindAllString(program, -1))
+}
+
+func TestIdentifyWrappersRuntime(t *testing.T) {
+	opts := Defaults()
+	opts.IdentifyWrappers = true
+	if _, err := Generate(opts); err == nil {
+		t.Fatal("--identify-wrappers accepted with an included csmith.h")
+	}
+
+	dir := t.TempDir()
+	opts.Runtime = runtimeWrite
+	opts.OutputPath = filepath.Join(dir, "program.c")
+	program, err := Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(program, "safe_math_report();") {
+		t.Fatal("main does not report the wrapper sites")
+	}
+	for _, name := range append([]string{"program.c.wrappers.json"}, runtimeHeaders...) {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	safeMath, err := os.ReadFile(filepath.Join(dir, "safe_math.h"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(safeMath), "safe_math_report") {
+		t.Fatal("the written safe_math.h does not define safe_math_report")
+	}
+}
+
+func TestWrappedTypesNeedRuntime(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		enable func(*Options)
+	}{
+		{"float", func(o *Options) { o.EnableFloat = true }},
+		{"int128", func(o *Options) { o.Int128 = true }},
+		{"uint128", func(o *Options) { o.UInt128 = true }},
+	} {
+		opts := Defaults()
+		tc.enable(&opts)
+		if _, err := Generate(opts); err == nil {
+			t.Fatalf("%s accepted with an included csmith.h", tc.name)
+		}
+		opts.SafeMath = false
+		if _, err := Generate(opts); err != nil {
+			t.Fatalf("%s without safe math: %v", tc.name, err)
+		}
+		opts.SafeMath = true
+		opts.Runtime = runtimeInline
+		if _, err := Generate(opts); err != nil {
+			t.Fatalf("%s with the inlined runtime: %v", tc.name, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package csmith
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"csmith/pkg/csmith/cast"
+)
+
+// splitProgram distributes the globals and functions of a generated program
+// over up to opts.MaxSplitFiles translation units that share one header. The
+// entry unit keeps main and csmith_compute_hash. Everything crossing units
+// loses its static storage and is declared extern (or prototyped) in the header.
+func splitProgram(f *cast.File, opts Options) (entry *cast.File, extra []outputFile) {
+	stem := "program"
+	if opts.OutputPath != "" {
+		stem = strings.TrimSuffix(filepath.Base(opts.OutputPath), filepath.Ext(opts.OutputPath))
+	}
+	headerName := stem + ".h"
+
+	var banner cast.Decl
+	var defines, includes, structs, externs, protos []cast.Decl
+	var undefined *cast.VarDecl
+	var globals []*cast.VarDecl
+	var funcs, entryFuncs []*cast.FuncDef
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *cast.Comment:
+			if banner == nil {
+				banner = d
+			}
+		case *cast.Directive:
+			// Definitions configuring the runtime, which the header includes.
+			defines = append(defines, d)
+		case *cast.Include:
+			if d.Path != "csmith.h" {
+				includes = append(includes, d)
+			}
+		case *cast.StructDecl:
+			structs = append(structs, d, &cast.Blank{})
+		case *cast.VarDecl:
+			if d.Name == "__undefined" {
+				undefined = d
+				continue
+			}
+			g := *d
+			g.Storage = ""
+			globals = append(globals, &g)
+			externs = append(externs, &cast.VarDecl{Storage: "extern", Type: d.Type, Name: d.Name})
+		case *cast.FuncDecl:
+			proto := *d
+			proto.Storage = ""
+			externalLinkage(&proto)
+			protos = append(protos, &proto)
+		case *cast.FuncDef:
+			if d.Name == "main" || d.Name == "csmith_compute_hash" {
+				entryFuncs = append(entryFuncs, d)
+				continue
+			}
+			def := *d
+			def.Storage = ""
+			externalLinkage(&def.FuncDecl)
+			funcs = append(funcs, &def)
+		}
+	}
+
+	guard := includeGuard(headerName)
+	header := &cast.File{}
+	if banner != nil {
+		addDecl(header, banner, &cast.Blank{})
+	}
+	addDecl(header,
+		&cast.Directive{Text: "ifndef " + guard},
+		&cast.Directive{Text: "define " + guard},
+		&cast.Blank{},
+	)
+	addDecl(header, defines...)
+	addDecl(header, runtimeDecl(opts))
+	addDecl(header, includes...)
+	addDecl(header, &cast.Blank{})
+	addDecl(header, structs...)
+	addDecl(header, externs...)
+	addDecl(header, &cast.Blank{})
+	addDecl(header, protos...)
+	addDecl(header, &cast.Blank{}, &cast.Directive{Text: "endif"})
+
+	// Only create units that receive at least one global or function.
+	n := min(max(1, opts.MaxSplitFiles), max(1, max(len(funcs), len(globals))))
+	units := make([]*cast.File, n)
+	for i := range units {
+		units[i] = &cast.File{}
+		if banner != nil {
+			addDecl(units[i], banner, &cast.Blank{})
+		}
+		addDecl(units[i], &cast.Include{Path: headerName}, &cast.Blank{})
+	}
+	if undefined != nil {
+		addDecl(units[0], undefined, &cast.Blank{})
+	}
+	// Globals and functions are dealt round-robin, so every unit both defines
+	// objects and references ones defined elsewhere.
+	for i, g := range globals {
+		addDecl(units[i%n], g)
+	}
+	for _, u := range units {
+		addDecl(u, &cast.Blank{})
+	}
+	for i, def := range funcs {
+		addDecl(units[i%n], def, &cast.Blank{})
+	}
+	for _, def := range entryFuncs {
+		addDecl(units[0], def, &cast.Blank{})
+	}
+
+	dir := opts.SplitFilesDir
+	extra = append(extra, outputFile{path: filepath.Join(dir, headerName), content: cast.Print(header)})
+	extra = append(extra, outputFile{path: filepath.Join(dir, stem+".c"), content: cast.Print(units[0])})
+	for i := 1; i < n; i++ {
+		extra = append(extra, outputFile{path: filepath.Join(dir, fmt.Sprintf("%s_%d.c", stem, i)), content: cast.Print(units[i])})
+	}
+	return units[0], extra
+}
+
+// includeGuard derives the include-guard macro for a header file name.
+func includeGuard(name string) string {
+	return strings.ToUpper(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, filepath.Base(name)))
+}
+
+// externalLinkage drops inlining from a function called across units: a C99
+// inline definition without static provides no external definition, and an
+// always_inline body is not visible to callers in other units.
+func externalLinkage(d *cast.FuncDecl) {
+	d.Inline = false
+	var attrs []string
+	for _, a := range d.Attrs {
+		if a != "always_inline" {
+			attrs = append(attrs, a)
+		}
+	}
+	d.Attrs = attrs
+}
@@ -0,0 +1,83 @@
+package csmith
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestSplitProgramLinks splits a program and checks that every global and
+// function is defined in exactly one unit; when gcc is available the units are
+// also compiled, linked and run.
+func TestSplitProgramLinks(t *testing.T) {
+	dir := t.TempDir()
+	opts := Defaults()
+	opts.Seed = 5
+	opts.MaxSplitFiles = 3
+	opts.SplitFilesDir = dir
+	opts.Runtime = runtimeWrite
+	opts.OutputPath = filepath.Join(dir, "prog.c")
+	if _, err := Generate(opts); err != nil {
+		t.Fatal(err)
+	}
+	units, err := filepath.Glob(filepath.Join(dir, "prog*.c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(units) != 3 {
+		t.Fatalf("units %v, want 3", units)
+	}
+	globalDef := regexp.MustCompile(`(?m)^[a-z][^=(;]*?\b(g_\d+)(?:\[\d+\])* = `)
+	funcDef := regexp.MustCompile(`(?m)^[a-z][^=;]*?\b(func_\d+|main)\([^;]*$`)
+	defined := map[string]string{}
+	for _, u := range units {
+		src, err := os.ReadFile(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(src), `#include "prog.h"`) {
+			t.Fatalf("%s does not include the shared header", u)
+		}
+		for _, m := range append(globalDef.FindAllStringSubmatch(string(src), -1), funcDef.FindAllStringSubmatch(string(src), -1)...) {
+			if prev, ok := defined[m[1]]; ok {
+				t.Fatalf("%s defined in %s and %s", m[1], prev, u)
+			}
+			defined[m[1]] = u
+		}
+	}
+	if defined["main"] == "" || defined["func_1"] == "" || len(defined) < 2*len(units) {
+		t.Fatalf("too few definitions found: %v", defined)
+	}
+
+	gcc, err := exec.LookPath("gcc")
+	if err != nil {
+		t.Skip("gcc not available")
+	}
+	exe := filepath.Join(dir, "prog")
+	build := exec.Command(gcc, append([]string{"-w", "-o", exe}, units...)...)
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("linking the split units: %v\n%s", err, out)
+	}
+	out, err := exec.Command(exe).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(out), "checksum = ") {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestValidateDefaultsSplitFilesDir(t *testing.T) {
+	t.Chdir(t.TempDir())
+	opts := Defaults()
+	opts.MaxSplitFiles = 2
+	if err := opts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(defaultSplitFilesDir); err != nil {
+		t.Fatal(err)
+	}
+}
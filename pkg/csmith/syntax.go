@@ -0,0 +1,126 @@
+package csmith
+
+import (
+	"fmt"
+
+	"csmith/pkg/csmith/cast"
+)
+
+// Small constructors for the C syntax tree, keeping the generator code close
+// to the text it produces.
+
+func ident(name string) cast.Expr {
+	return &cast.Ident{Name: name}
+}
+
+func lit(format string, args ...any) cast.Expr {
+	return &cast.Lit{Text: fmt.Sprintf(format, args...)}
+}
+
+func paren(x cast.Expr) cast.Expr {
+	return &cast.Paren{X: x}
+}
+
+func binary(x cast.Expr, op string, y cast.Expr) cast.Expr {
+	return &cast.Binary{X: x, Op: op, Y: y}
+}
+
+func assign(lhs cast.Expr, op string, rhs cast.Expr) cast.Expr {
+	return &cast.Assign{LHS: lhs, Op: op, RHS: rhs}
+}
+
+func call(name string, args ...cast.Expr) cast.Expr {
+	return &cast.Call{Fun: ident(name), Args: args}
+}
+
+func deref(x cast.Expr) cast.Expr {
+	return &cast.Unary{Op: "*", X: x}
+}
+
+func addrOf(x cast.Expr) cast.Expr {
+	return &cast.Unary{Op: "&", X: x}
+}
+
+func index(x, i cast.Expr) cast.Expr {
+	return &cast.Index{X: x, Index: i}
+}
+
+func exprStmt(x cast.Expr) cast.Stmt {
+	return &cast.ExprStmt{X: x}
+}
+
+func addStmt(blk *cast.Block, stmts ...cast.Stmt) {
+	blk.Stmts = append(blk.Stmts, stmts...)
+}
+
+func addDecl(f *cast.File, decls ...cast.Decl) {
+	f.Decls = append(f.Decls, decls...)
+}
+
+// mixIntoX folds v, a value of type t, into the running checksum local:
+// "x ^= (uint32_t)v;".
+func mixIntoX(v cast.Expr, t CType, opts Options) cast.Stmt {
+	u32 := CType{Name: "uint32_t", Signed: false, Bits: 32}
+	if t.Float {
+		return exprStmt(assign(ident("x"), "^=", convertExpr(t, u32, v, opts)))
+	}
+	return exprStmt(assign(ident("x"), "^=", &cast.Cast{Type: typeNode(u32), X: v}))
+}
+
+// nullPointer is the null pointer constant: nullptr in C++11, 0 otherwise.
+func nullPointer(opts Options) cast.Expr {
+	if opts.LangCPP && opts.CPP11 {
+		return lit("nullptr")
+	}
+	return lit("0")
+}
+
+func typeNode(t CType) cast.Type {
+	return &cast.Named{Name: t.Name}
+}
+
+func qualOf(isConst, isVolatile bool) cast.Qual {
+	var q cast.Qual
+	if isConst {
+		q |= cast.Const
+	}
+	if isVolatile {
+		q |= cast.Volatile
+	}
+	return q
+}
+
+func qualTypeNode(t CType, isConst, isVolatile bool) cast.Type {
+	return &cast.Named{Name: t.Name, Qual: qualOf(isConst, isVolatile)}
+}
+
+// castExpr renders "((T)(x))", the explicit conversion used for every typed value.
+func castExpr(t CType, x cast.Expr) cast.Expr {
+	return &cast.Paren{X: &cast.Cast{Type: typeNode(t), X: &cast.Paren{X: x}}}
+}
+
+// convertExpr converts x, a value of type from, to type to. Converting an
+// out-of-range floating-point value to an integer type is undefined, so with
+// safe math such conversions go through safe_convert_func_<fp>_to_int32_t.
+func convertExpr(from, to CType, x cast.Expr, opts Options) cast.Expr {
+	if from.Float && !to.Float && opts.SafeMath {
+		return castExpr(to, call(fmt.Sprintf("safe_convert_func_%s_to_int32_t", from.Name), x))
+	}
+	return castExpr(to, x)
+}
+
+func scalarField(t CType, q cast.Qual, name string) *cast.Field {
+	return &cast.Field{Type: &cast.Named{Name: t.Name, Qual: q}, Name: name, BitWidth: -1}
+}
+
+func bitField(base string, q cast.Qual, name string, width int) *cast.Field {
+	return &cast.Field{Type: &cast.Named{Name: base, Qual: q}, Name: name, BitWidth: width}
+}
+
+func funcDecl(fn funcInfo) cast.FuncDecl {
+	d := cast.FuncDecl{Storage: "static", Inline: fn.inline, Attrs: fn.attrs, Ret: typeNode(fn.ret), Name: fn.name}
+	for _, p := range fn.params {
+		d.Params = append(d.Params, &cast.Param{Type: typeNode(p.ctype), Name: p.name})
+	}
+	return d
+}
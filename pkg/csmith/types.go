@@ -1,14 +1,27 @@
 package csmith
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // CType is a lightweight C scalar type descriptor used by the current generator.
+// Float marks the floating-point types, which are always Signed. Aggregate
+// marks struct and union types, which are copied whole and otherwise accessed
+// through their members.
 type CType struct {
-	Name   string
-	Signed bool
-	Bits   int
+	Name      string
+	Signed    bool
+	Bits      int
+	Float     bool
+	Aggregate bool
 }
 
+var (
+	floatType  = CType{Name: "float", Signed: true, Bits: 32, Float: true}
+	doubleType = CType{Name: "double", Signed: true, Bits: 64, Float: true}
+)
+
 func hostIntType(opts Options) CType {
 	switch opts.IntSize {
 	case 1:
@@ -39,8 +52,9 @@ func typePool(opts Options) []CType {
 	// Mirrors Type::GenerateSimpleTypes order:
 	// eChar, eSChar, eUChar, eShort, eUShort, eInt, eUInt,
 	// eLong, eULong, eLongLong, eULongLong, eInt128, eUInt128.
-	// Keep entries even when aliases collapse to same C type to preserve
-	// upstream RNG selection cardinality.
+	// Keep entries even when aliases collapse to same C type, and keep the
+	// 128-bit entries even when they are disabled, to preserve upstream RNG
+	// selection cardinality; disabled entries are filtered when picking.
 	pool := make([]CType, 0, 13)
 	pool = append(pool, CType{Name: "int8_t", Signed: true, Bits: 8})   // char
 	pool = append(pool, CType{Name: "int8_t", Signed: true, Bits: 8})   // signed char
@@ -57,13 +71,100 @@ func typePool(opts Options) []CType {
 	}
 	pool = append(pool, CType{Name: "__int128", Signed: true, Bits: 128})
 	pool = append(pool, CType{Name: "unsigned __int128", Signed: false, Bits: 128})
+	if opts.EnableFloat {
+		pool = append(pool, floatType, doubleType)
+	}
 	return pool
 }
 
-func pickType(r *rng, pool []CType) CType {
-	return pool[int(r.upto(uint32(len(pool))))]
+// typeEnabled reports whether values of simple type t may be generated.
+func typeEnabled(t CType, opts Options) bool {
+	if t.Bits == 128 {
+		if t.Signed {
+			return opts.Int128
+		}
+		return opts.UInt128
+	}
+	return true
+}
+
+// disabledTypeFilter rejects indices of disabled pool entries, mirroring
+// upstream SIMPLE_TYPES_PROB_FILTER. Indices past the pool (aggregate types)
+// are accepted.
+func disabledTypeFilter(pool []CType, opts Options) func(uint32) bool {
+	probs := simpleTypeProbs(opts)
+	return func(i uint32) bool {
+		if int(i) >= len(pool) {
+			return false
+		}
+		if !typeEnabled(pool[i], opts) {
+			return true
+		}
+		return int(i) < len(probs) && probs[i] != noProb && opts.prob(probs[i]) == 0
+	}
+}
+
+// simpleTypeProbs lists, entry by entry of typePool, the simple-type group
+// member that switches the type on; the 128-bit types have none.
+func simpleTypeProbs(opts Options) []probID {
+	ids := []probID{probChar, probChar, probUChar, probShort, probUShort, probInt, probUInt, probLong, probULong}
+	if opts.LongLong {
+		ids = append(ids, probLongLong, probULongLong)
+	}
+	ids = append(ids, noProb, noProb)
+	if opts.EnableFloat {
+		ids = append(ids, probFloat, probFloat)
+	}
+	return ids
+}
+
+func pickType(r *rng, opts Options, pool []CType) CType {
+	return pool[int(r.uptoWithFilter(uint32(len(pool)), disabledTypeFilter(pool, opts)))]
+}
+
+// isUnion reports whether t is a union type.
+func (t CType) isUnion() bool {
+	return t.Aggregate && strings.HasPrefix(t.Name, "union ")
+}
+
+func structType(i int) CType {
+	return CType{Name: fmt.Sprintf("struct S%d", i), Bits: 32, Aggregate: true}
 }
 
-func castLiteral(t CType, expr string) string {
-	return fmt.Sprintf("((%s)(%s))", t.Name, expr)
+func unionType(i int) CType {
+	return CType{Name: fmt.Sprintf("union U%d", i), Bits: 32, Aggregate: true}
+}
+
+// pickAnyType picks over the simple types followed by the struct and union
+// types, as upstream does over AllTypes. Structs and unions are only eligible
+// when the corresponding flag allows them.
+func pickAnyType(r *rng, opts Options, pool []CType, info compositeInfo, structs, unions bool) CType {
+	return pickAnyTypeWhere(r, opts, pool, info, func(t CType) bool {
+		if t.isUnion() {
+			return unions
+		}
+		return structs
+	})
+}
+
+// pickAnyTypeWhere is pickAnyType with the aggregates filtered by accept.
+func pickAnyTypeWhere(r *rng, opts Options, pool []CType, info compositeInfo, accept func(CType) bool) CType {
+	n := len(pool) + len(info.structs) + len(info.unions)
+	disabled := disabledTypeFilter(pool, opts)
+	at := func(i int) CType {
+		switch {
+		case i < len(pool):
+			return pool[i]
+		case i < len(pool)+len(info.structs):
+			return structType(i - len(pool))
+		}
+		return unionType(i - len(pool) - len(info.structs))
+	}
+	i := int(r.uptoWithFilter(uint32(n), func(v uint32) bool {
+		if int(v) < len(pool) {
+			return disabled(v)
+		}
+		return !accept(at(int(v)))
+	}))
+	return at(i)
 }
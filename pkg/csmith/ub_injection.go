@@ -0,0 +1,145 @@
+package csmith
+
+import (
+	"encoding/json"
+	"strings"
+
+	"csmith/pkg/csmith/cast"
+)
+
+// ubKind names a kind of deliberately injected undefined behaviour.
+type ubKind string
+
+const (
+	ubArrayOOB         ubKind = "array-oob"
+	ubNullPtrDeref     ubKind = "null-ptr-deref"
+	ubDanglingPtrDeref ubKind = "dangling-ptr-deref"
+)
+
+// ubMarker prefixes the comment placed on the line before each injected site;
+// the manifest is recovered from the printed sources by looking for it.
+const ubMarker = "/* UB: "
+
+// ubSite is one manifest entry.
+type ubSite struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Kind string `json:"kind"`
+}
+
+// injectsUB reports whether any undefined-behaviour kind is requested.
+func (o Options) injectsUB() bool {
+	return o.ArrayOOBProb > 0 || o.NullPtrDerefProb > 0 || o.DanglingPtrDerefProb > 0
+}
+
+// maybeInjectUB draws, per kind, whether to inject an undefined-behaviour site
+// before the next statement of blk. Kinds with probability 0 draw nothing, so
+// programs generated without injection are unaffected.
+func maybeInjectUB(blk *cast.Block, r *rng, opts Options, env envInfo, ctx *genContext) {
+	if n := len(blk.Stmts); n > 0 {
+		switch blk.Stmts[n-1].(type) {
+		case *cast.Return, *cast.Break, *cast.Continue, *cast.Goto:
+			// The site would be unreachable.
+			return
+		}
+	}
+	if opts.ArrayOOBProb > 0 && r.flipcoin(uint32(opts.ArrayOOBProb)) {
+		injectArrayOOB(blk, r, opts, env, ctx)
+	}
+	if opts.NullPtrDerefProb > 0 && r.flipcoin(uint32(opts.NullPtrDerefProb)) {
+		injectNullPtrDeref(blk, r, opts, ctx)
+	}
+	if opts.DanglingPtrDerefProb > 0 && r.flipcoin(uint32(opts.DanglingPtrDerefProb)) {
+		injectDanglingPtrDeref(blk, r, opts, ctx)
+	}
+}
+
+func ubComment(kind ubKind) cast.Stmt {
+	return &cast.Comment{Text: ubMarker + string(kind) + " */"}
+}
+
+// isUBMarker reports whether s is the marker comment of an injected site.
+func isUBMarker(s cast.Stmt) bool {
+	c, ok := s.(*cast.Comment)
+	return ok && strings.HasPrefix(c.Text, ubMarker)
+}
+
+func ubLocalName(ctx *genContext) string {
+	if ctx != nil && ctx.state != nil {
+		return ctx.state.allocLocalName()
+	}
+	return "l_ub"
+}
+
+// ubType picks the type of the object an injected pointer refers to.
+func ubType(r *rng, opts Options, ctx *genContext) CType {
+	if ctx != nil && ctx.state != nil && len(ctx.state.pool) > 0 {
+		return pickType(r, opts, ctx.state.pool)
+	}
+	return CType{Name: "uint32_t", Signed: false, Bits: 32}
+}
+
+// injectArrayOOB reads one element past the first dimension of an existing
+// array, or of a fresh local array when none exists.
+func injectArrayOOB(blk *cast.Block, r *rng, opts Options, env envInfo, ctx *genContext) {
+	var a arrayInfo
+	if arrays := mergedArrays(env, ctx); len(arrays) > 0 {
+		a = arrays[int(r.upto(uint32(len(arrays))))]
+	} else {
+		a = arrayInfo{
+			name:  ubLocalName(ctx),
+			ctype: CType{Name: "uint32_t", Signed: false, Bits: 32},
+			dims:  []int{1 + int(r.upto(uint32(max(1, opts.MaxArrayLenPerDim))))},
+		}
+		addStmt(blk, &cast.VarDecl{Type: a.declType(), Name: a.name, Init: &cast.InitList{Elems: []cast.Expr{lit("0")}}})
+	}
+	idx := make([]cast.Expr, len(a.dims))
+	idx[0] = lit("%d", a.dims[0])
+	for k := 1; k < len(a.dims); k++ {
+		idx[k] = lit("%d", int(r.upto(uint32(a.dims[k]))))
+	}
+	addStmt(blk, ubComment(ubArrayOOB))
+	addStmt(blk, mixIntoX(a.access(idx), a.ctype, opts))
+}
+
+// injectNullPtrDeref reads through a local pointer initialized to null.
+func injectNullPtrDeref(blk *cast.Block, r *rng, opts Options, ctx *genContext) {
+	t := ubType(r, opts, ctx)
+	p := ubLocalName(ctx)
+	addStmt(blk, &cast.VarDecl{Type: &cast.Pointer{Elem: typeNode(t)}, Name: p, Init: nullPointer(opts)})
+	addStmt(blk, ubComment(ubNullPtrDeref))
+	addStmt(blk, mixIntoX(deref(ident(p)), t, opts))
+}
+
+// injectDanglingPtrDeref reads through a pointer to a local whose enclosing
+// block has ended.
+func injectDanglingPtrDeref(blk *cast.Block, r *rng, opts Options, ctx *genContext) {
+	t := ubType(r, opts, ctx)
+	p, v := ubLocalName(ctx), ubLocalName(ctx)
+	addStmt(blk, &cast.VarDecl{Type: &cast.Pointer{Elem: typeNode(t)}, Name: p, Init: nullPointer(opts)})
+	addStmt(blk, &cast.Block{Stmts: []cast.Stmt{
+		&cast.VarDecl{Type: typeNode(t), Name: v, Init: randomConstantExpr(t, r, opts)},
+		exprStmt(assign(ident(p), "=", addrOf(ident(v)))),
+	}})
+	addStmt(blk, ubComment(ubDanglingPtrDeref))
+	addStmt(blk, mixIntoX(deref(ident(p)), t, opts))
+}
+
+// ubManifest lists the injected sites found in files as JSON. Each site is the
+// line following its marker comment.
+func ubManifest(files []outputFile) string {
+	sites := []ubSite{}
+	for _, f := range files {
+		lines := strings.Split(f.content, "\n")
+		for i, l := range lines {
+			l = strings.TrimSpace(l)
+			if !strings.HasPrefix(l, ubMarker) {
+				continue
+			}
+			kind := strings.TrimSuffix(strings.TrimPrefix(l, ubMarker), " */")
+			sites = append(sites, ubSite{File: f.path, Line: i + 2, Kind: kind})
+		}
+	}
+	out, _ := json.MarshalIndent(sites, "", "  ")
+	return string(out) + "\n"
+}
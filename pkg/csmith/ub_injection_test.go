@@ -0,0 +1,75 @@
+package csmith
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func ubOptions(seed uint64) Options {
+	opts := Defaults()
+	opts.Seed = seed
+	opts.ArrayOOBProb = 20
+	opts.NullPtrDerefProb = 20
+	opts.DanglingPtrDerefProb = 20
+	return opts
+}
+
+// TestUBManifestLines checks that every manifest entry points at the line
+// right after its marker comment in the generated program.
+func TestUBManifestLines(t *testing.T) {
+	dir := t.TempDir()
+	sites := 0
+	for seed := uint64(1); seed <= 5; seed++ {
+		opts := ubOptions(seed)
+		opts.OutputPath = filepath.Join(dir, "program.c")
+		program, err := Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(opts.OutputPath + ".ub.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var manifest []ubSite
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(program, "\n")
+		for _, s := range manifest {
+			if s.File != opts.OutputPath {
+				t.Fatalf("seed %d: site in %q, want %q", seed, s.File, opts.OutputPath)
+			}
+			if s.Line < 2 || s.Line > len(lines) {
+				t.Fatalf("seed %d: line %d out of range", seed, s.Line)
+			}
+			marker := strings.TrimSpace(lines[s.Line-2])
+			if marker != ubMarker+s.Kind+" */" {
+				t.Fatalf("seed %d: line %d follows %q, want the %s marker", seed, s.Line, marker, s.Kind)
+			}
+			if stmt := strings.TrimSpace(lines[s.Line-1]); !strings.HasPrefix(stmt, "x ^= ") {
+				t.Fatalf("seed %d: line %d is %q, want the faulty read", seed, s.Line, stmt)
+			}
+		}
+		sites += len(manifest)
+	}
+	if sites == 0 {
+		t.Fatal("no undefined-behaviour sites injected")
+	}
+}
+
+func TestUBManifestNeedsOutputPath(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if _, err := Generate(ubOptions(1)); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("generating to stdout wrote %v", entries)
+	}
+}
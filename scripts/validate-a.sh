@@ -10,7 +10,9 @@ COUNT="${COUNT:-20}"
 SEED_START="${SEED_START:-1}"
 TIMEOUT_SECS="${TIMEOUT_SECS:-3}"
 WORKDIR="${WORKDIR:-/tmp/csmith-go-validate}"
-RUNTIME_INCLUDE="${RUNTIME_INCLUDE:-/nix/store/hrf9nixgjz33q1563l9bxx155py477qv-csmith-2.3.0/include/csmith-2.3.0}"
+# Without RUNTIME_INCLUDE, programs are generated with the embedded runtime
+# inlined and need no Csmith installation.
+RUNTIME_INCLUDE="${RUNTIME_INCLUDE:-}"
 CANDIDATE_CMD="${CANDIDATE_CMD:-GOCACHE=/tmp/go-cache go run ./cmd/csmith-go}"
 STRICT_SAN="${STRICT_SAN:-0}"
 SAN_FLAGS="${SAN_FLAGS:--fsanitize=undefined -fno-sanitize-recover=all}"
@@ -64,6 +66,13 @@ fi
 
 mkdir -p "$WORKDIR"
 
+runtime_gen_flags="--runtime inline"
+runtime_cflags=()
+if [[ -n "$RUNTIME_INCLUDE" ]]; then
+  runtime_gen_flags=""
+  runtime_cflags=(-I"$RUNTIME_INCLUDE")
+fi
+
 pass=0
 fail=0
 
@@ -99,15 +108,15 @@ for ((i=0; i<COUNT; i++)); do
   base="$WORKDIR/seed_${seed}"
   src="${base}.c"
 
-  eval "$CANDIDATE_CMD --seed ${seed}" > "$src"
+  eval "$CANDIDATE_CMD --seed ${seed} ${runtime_gen_flags}" > "$src"
 
   gcc_o0="${base}.gcc.o0"
   clang_o0="${base}.clang.o0"
   gcc_san="${base}.gcc.san"
   clang_san="${base}.clang.san"
 
-  gcc -std=c11 -O0 "$src" -I"$RUNTIME_INCLUDE" -o "$gcc_o0"
-  clang -std=c11 -O0 "$src" -I"$RUNTIME_INCLUDE" -o "$clang_o0"
+  gcc -std=c11 -O0 "$src" ${runtime_cflags[@]+"${runtime_cflags[@]}"} -o "$gcc_o0"
+  clang -std=c11 -O0 "$src" ${runtime_cflags[@]+"${runtime_cflags[@]}"} -o "$clang_o0"
 
   out_gcc="${base}.gcc.out"
   out_clang="${base}.clang.out"
@@ -122,7 +131,7 @@ for ((i=0; i<COUNT; i++)); do
   fi
 
   if [[ "$gcc_san_enabled" -eq 1 ]]; then
-    if ! gcc -std=c11 -O1 "${SAN_ARR[@]}" "$src" -I"$RUNTIME_INCLUDE" -o "$gcc_san"; then
+    if ! gcc -std=c11 -O1 "${SAN_ARR[@]}" "$src" ${runtime_cflags[@]+"${runtime_cflags[@]}"} -o "$gcc_san"; then
       echo "[FAIL] seed=${seed}: gcc sanitizer build failed"
       fail=$((fail + 1))
       continue
@@ -135,7 +144,7 @@ for ((i=0; i<COUNT; i++)); do
   fi
 
   if [[ "$clang_san_enabled" -eq 1 ]]; then
-    if ! clang -std=c11 -O1 "${SAN_ARR[@]}" "$src" -I"$RUNTIME_INCLUDE" -o "$clang_san"; then
+    if ! clang -std=c11 -O1 "${SAN_ARR[@]}" "$src" ${runtime_cflags[@]+"${runtime_cflags[@]}"} -o "$clang_san"; then
       echo "[FAIL] seed=${seed}: clang sanitizer build failed"
       fail=$((fail + 1))
       continue